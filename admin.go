@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"snowflake/errdetail"
+	pb "snowflake/proto"
+	"snowflake/store"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+// sequence administration, sequences are stored as <pk-root>/<name> -> value
+
+// create a sequence with an initial value
+func (s *server) Create(ctx context.Context, in *pb.Snowflake_KeyValue) (*pb.Snowflake_Value, error) {
+	key, err := s.seq_key(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the value and the options are created atomically,
+	// default options are written too, overriding stale ones.
+	o := default_options
+	opts := &o
+	if in.Options != nil {
+		if opts, err = new_options(in.Options); err != nil {
+			return nil, status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "%v", err)
+		}
+	}
+	if !opts.start_in_range(in.Value) {
+		return nil, status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "value %v out of the range of min_value and max_value", in.Value)
+	}
+	okv, err := s.options_kv(in.Name, opts)
+	if err != nil {
+		return nil, status_error(ctx, codes.Internal, errdetail.INTERNAL, "%v", err)
+	}
+	extra := []*store.KV{okv}
+	if in.Format != nil {
+		f, err := new_format(in.Format)
+		if err != nil {
+			return nil, status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "%v", err)
+		}
+		fkv, err := s.format_kv(in.Name, f)
+		if err != nil {
+			return nil, status_error(ctx, codes.Internal, errdetail.INTERNAL, "%v", err)
+		}
+		extra = append(extra, fkv)
+	}
+
+	err = s.store.Create(ctx, key, []byte(fmt.Sprint(in.Value)), extra...)
+	if err == store.ErrExists {
+		return nil, status_error(ctx, codes.AlreadyExists, errdetail.KEY_EXISTS, "sequence %v already exists", in.Name)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+	s.drop_segment(in.Name)
+	return &pb.Snowflake_Value{Value: in.Value}, nil
+}
+
+// delete a sequence, with the counters of its periods if it resets
+func (s *server) Delete(ctx context.Context, in *pb.Snowflake_Key) (*pb.Snowflake_Value, error) {
+	key, err := s.seq_key(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := s.load_options(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := s.store.Delete(ctx, key, s.options_key(in.Name), s.format_key(in.Name))
+	if err == store.ErrNotFound {
+		return nil, not_found_error(ctx, in.Name)
+	}
+	if err == store.ErrConflict {
+		return nil, conflict_error(ctx, in.Name)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+	s.drop_segment(in.Name)
+
+	// one by one, there may be more periods than a transaction takes
+	if opts.ResetPeriod != "" {
+		s.delete_buckets(ctx, in.Name)
+	}
+
+	value, _ := strconv.ParseInt(string(kv.Value), 10, 64)
+	return &pb.Snowflake_Value{Value: value}, nil
+}
+
+// delete_buckets deletes the counters of periods of a reset sequence,
+// sequences nested under the name have their own buckets, which are kept.
+func (s *server) delete_buckets(ctx context.Context, name string) {
+	prefix := s.bucket_key(name, "")
+	buckets, err := s.store.List(ctx, prefix)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, kv := range buckets {
+		if strings.Contains(strings.TrimPrefix(kv.Key, prefix), "/") {
+			continue
+		}
+		if _, err := s.store.Delete(ctx, kv.Key); err != nil && err != store.ErrNotFound {
+			log.Error(err)
+		}
+	}
+}
+
+// list all sequences under pk-root
+func (s *server) List(ctx context.Context, in *pb.Snowflake_NullRequest) (*pb.Snowflake_KeyValues, error) {
+	kvs, err := s.store.List(ctx, s.pkroot+"/")
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+
+	ret := &pb.Snowflake_KeyValues{}
+	for _, kv := range kvs {
+		// machine ids and options are not sequences
+		if kv.Key == s.uuidkey || strings.HasPrefix(kv.Key, s.uuidkey+"/") {
+			continue
+		}
+		name := strings.TrimPrefix(kv.Key, s.pkroot+"/")
+		if is_hidden(name) {
+			continue
+		}
+		value, err := strconv.ParseInt(string(kv.Value), 10, 64)
+		if err != nil {
+			continue
+		}
+		ret.Keys = append(ret.Keys, &pb.Snowflake_KeyValue{Name: name, Value: value})
+	}
+	return ret, nil
+}
+
+// read the current value of a sequence without incrementing,
+// the counter of the current period for reset sequences.
+func (s *server) Get(ctx context.Context, in *pb.Snowflake_Key) (*pb.Snowflake_Value, error) {
+	key, err := s.seq_key(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := s.store.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return nil, not_found_error(ctx, in.Name)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+
+	// a period not started yet counts from the value of the sequence
+	opts, err := s.load_options(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := opts.bucket(s.now())
+	if err != nil {
+		return nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed options of %v: %v", in.Name, err)
+	}
+	if bucket != "" {
+		bkv, err := s.store.Get(ctx, s.bucket_key(in.Name, bucket))
+		if err != nil && err != store.ErrNotFound {
+			log.Error(err)
+			return nil, store_error(ctx, err)
+		}
+		if err == nil {
+			kv = bkv
+		}
+	}
+	value, err := strconv.ParseInt(string(kv.Value), 10, 64)
+	if err != nil {
+		return nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed value of %v: %q", in.Name, kv.Value)
+	}
+	return &pb.Snowflake_Value{Value: value}, nil
+}
+
+// set the value of a sequence if the current value equals prev_value
+func (s *server) Set(ctx context.Context, in *pb.Snowflake_CompareAndSet) (*pb.Snowflake_Value, error) {
+	key, err := s.seq_key(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := s.store.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return nil, not_found_error(ctx, in.Name)
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+	if string(kv.Value) != fmt.Sprint(in.PrevValue) {
+		return nil, status_error(ctx, codes.FailedPrecondition, errdetail.VALUE_CHANGED, "sequence %v has been changed", in.Name)
+	}
+
+	// the revision guards against writes between the compare and the swap
+	err = s.store.CompareAndSwap(ctx, key, []byte(fmt.Sprint(in.Value)), kv.Revision)
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		return nil, not_found_error(ctx, in.Name)
+	case store.ErrConflict:
+		return nil, status_error(ctx, codes.FailedPrecondition, errdetail.VALUE_CHANGED, "sequence %v has been changed", in.Name)
+	default:
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+	s.drop_segment(in.Name)
+	return &pb.Snowflake_Value{Value: in.Value}, nil
+}
+
+// seq_key returns the key of a sequence
+func (s *server) seq_key(ctx context.Context, name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || is_hidden(name) {
+		return "", status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "invalid sequence name: %q", name)
+	}
+	key := s.pkroot + "/" + name
+	if key == s.uuidkey || strings.HasPrefix(key, s.uuidkey+"/") {
+		return "", status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "reserved sequence name: %q", name)
+	}
+	return key, nil
+}
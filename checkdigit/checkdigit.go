@@ -0,0 +1,120 @@
+// Package checkdigit computes and verifies check digits of decimal strings,
+// so that typos in identifiers are caught before reaching the database.
+package checkdigit
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotDigits = errors.New("checkdigit: not a string of decimal digits")
+
+// Algorithm appends and verifies one check digit
+type Algorithm interface {
+	// Compute returns the check digit of digits
+	Compute(digits string) (byte, error)
+	// Verify checks the last digit is the check digit of the others
+	Verify(digits string) bool
+}
+
+var (
+	// Luhn is the mod 10 algorithm of credit card numbers, catches single digit errors
+	// and most transpositions of adjacent digits
+	Luhn Algorithm = luhn{}
+	// Damm catches all single digit errors and all transpositions of adjacent digits
+	Damm Algorithm = damm{}
+)
+
+var algorithms = map[string]Algorithm{
+	"luhn": Luhn,
+	"damm": Damm,
+}
+
+// ByName returns the algorithm of a name, luhn or damm
+func ByName(name string) (Algorithm, error) {
+	if a, ok := algorithms[name]; ok {
+		return a, nil
+	}
+	return nil, fmt.Errorf("unknown check digit algorithm: %v", name)
+}
+
+type luhn struct{}
+
+func (luhn) Compute(digits string) (byte, error) {
+	sum, err := luhn_sum(digits, true)
+	if err != nil {
+		return 0, err
+	}
+	return byte('0' + (10-sum%10)%10), nil
+}
+
+func (luhn) Verify(digits string) bool {
+	sum, err := luhn_sum(digits, false)
+	return err == nil && len(digits) > 1 && sum%10 == 0
+}
+
+// luhn_sum doubles every second digit from the right, starting with the rightmost if double
+func luhn_sum(digits string, double bool) (int, error) {
+	if len(digits) == 0 {
+		return 0, ErrNotDigits
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if d < 0 || d > 9 {
+			return 0, ErrNotDigits
+		}
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum, nil
+}
+
+type damm struct{}
+
+// damm_table is a totally anti-symmetric quasigroup of order 10
+var damm_table = [10][10]byte{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+func (damm) Compute(digits string) (byte, error) {
+	interim, err := damm_interim(digits)
+	if err != nil {
+		return 0, err
+	}
+	return '0' + interim, nil
+}
+
+func (damm) Verify(digits string) bool {
+	interim, err := damm_interim(digits)
+	return err == nil && len(digits) > 1 && interim == 0
+}
+
+func damm_interim(digits string) (byte, error) {
+	if len(digits) == 0 {
+		return 0, ErrNotDigits
+	}
+	var interim byte
+	for i := 0; i < len(digits); i++ {
+		d := digits[i] - '0'
+		if d > 9 {
+			return 0, ErrNotDigits
+		}
+		interim = damm_table[interim][d]
+	}
+	return interim, nil
+}
@@ -0,0 +1,47 @@
+package checkdigit
+
+import "testing"
+
+func TestCompute(t *testing.T) {
+	for _, c := range []struct {
+		a      Algorithm
+		digits string
+		check  byte
+	}{
+		{Luhn, "7992739871", '3'},
+		{Luhn, "0", '0'},
+		{Luhn, "20161018000123", '5'},
+		{Damm, "572", '4'},
+		{Damm, "0", '0'},
+		{Damm, "20161018000123", '1'},
+	} {
+		check, err := c.a.Compute(c.digits)
+		if err != nil || check != c.check {
+			t.Fatalf("%T %v: expect %c, got %c %v", c.a, c.digits, c.check, check, err)
+		}
+		if !c.a.Verify(c.digits + string(check)) {
+			t.Fatalf("%T %v%c should be valid", c.a, c.digits, check)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, a := range []Algorithm{Luhn, Damm} {
+		// single digit errors and adjacent transpositions
+		for _, v := range []string{"79927398710", "79927398731", "97927398713", "5742", "7524", "", "5", "57a4"} {
+			if a.Verify(v) {
+				t.Fatalf("%T %q should be invalid", a, v)
+			}
+		}
+		if _, err := a.Compute("12x"); err != ErrNotDigits {
+			t.Fatalf("%T: expect ErrNotDigits, got %v", a, err)
+		}
+	}
+
+	if a, err := ByName("damm"); err != nil || a != Damm {
+		t.Fatal(a, err)
+	}
+	if _, err := ByName("verhoeff"); err == nil {
+		t.Fatal("unknown algorithm accepted")
+	}
+}
@@ -0,0 +1,97 @@
+// Package errdetail describes errors of the snowflake service with trailer metadata,
+// so that clients can decide whether to retry without parsing messages.
+//
+//	var trailer metadata.MD
+//	_, err := client.Next(ctx, key, grpc.Trailer(&trailer))
+//	if d, ok := errdetail.FromMD(trailer); ok && d.Retryable {
+//		time.Sleep(d.RetryAfter)
+//	}
+package errdetail
+
+import (
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// trailer metadata keys
+const (
+	REASON_KEY      = "snowflake-reason"
+	RETRYABLE_KEY   = "snowflake-retryable"
+	RETRY_AFTER_KEY = "snowflake-retry-after" // milliseconds
+)
+
+// reasons of errors, each maps to one grpc code
+const (
+	KEY_NOT_FOUND      = "KEY_NOT_FOUND"      // NotFound, the sequence must be created first
+	KEY_EXISTS         = "KEY_EXISTS"         // AlreadyExists
+	INVALID_ARGUMENT   = "INVALID_ARGUMENT"   // InvalidArgument
+	VALUE_CHANGED      = "VALUE_CHANGED"      // FailedPrecondition, the value differs from prev_value of Set
+	OUT_OF_RANGE       = "OUT_OF_RANGE"       // OutOfRange, the sequence reached its bound without cycle
+	NO_CHECK_DIGIT     = "NO_CHECK_DIGIT"     // FailedPrecondition, Validate on a sequence formatted without check digit
+	MALFORMED_VALUE    = "MALFORMED_VALUE"    // DataLoss, the stored value or options are corrupt
+	STORE_UNAVAILABLE  = "STORE_UNAVAILABLE"  // Unavailable, the storage backend failed
+	TOO_MANY_CONFLICTS = "TOO_MANY_CONFLICTS" // Aborted, concurrent updates kept winning
+	QUEUE_FULL         = "QUEUE_FULL"         // ResourceExhausted, too many requests waiting for the store
+	CLOCK_BACKWARD     = "CLOCK_BACKWARD"     // Unavailable, the clock shifted backward
+	MACHINE_ID_EXPIRED = "MACHINE_ID_EXPIRED" // Unavailable, the lease of the machine id could not be renewed in time
+	HIGH_WATER_STALE   = "HIGH_WATER_STALE"   // Unavailable, the clock passed the last persisted high-water
+	CLOCK_UNUSABLE     = "CLOCK_UNUSABLE"     // FailedPrecondition, the clock is before the epoch or beyond the layout
+	DEADLINE_EXCEEDED  = "DEADLINE_EXCEEDED"  // DeadlineExceeded
+	CANCELED           = "CANCELED"           // Canceled
+	INTERNAL           = "INTERNAL"           // Internal
+)
+
+// retryable reasons, the same request may succeed later
+var retryable = map[string]bool{
+	STORE_UNAVAILABLE:  true,
+	TOO_MANY_CONFLICTS: true,
+	QUEUE_FULL:         true,
+	CLOCK_BACKWARD:     true,
+	MACHINE_ID_EXPIRED: true,
+	HIGH_WATER_STALE:   true,
+	DEADLINE_EXCEEDED:  true,
+}
+
+// Detail is the machine-readable part of an error
+type Detail struct {
+	Reason     string
+	Retryable  bool
+	RetryAfter time.Duration // suggested delay before retrying, 0 for none
+}
+
+// New returns the detail of a reason
+func New(reason string) Detail {
+	return Detail{Reason: reason, Retryable: retryable[reason]}
+}
+
+// MD encodes the detail as metadata
+func (d Detail) MD() metadata.MD {
+	md := metadata.Pairs(REASON_KEY, d.Reason, RETRYABLE_KEY, strconv.FormatBool(d.Retryable))
+	if d.RetryAfter > 0 {
+		md[RETRY_AFTER_KEY] = []string{strconv.FormatInt(int64(d.RetryAfter/time.Millisecond), 10)}
+	}
+	return md
+}
+
+// FromMD decodes the detail from trailer metadata, false if the trailer carries none
+func FromMD(md metadata.MD) (Detail, bool) {
+	reason := first(md, REASON_KEY)
+	if reason == "" {
+		return Detail{}, false
+	}
+	d := Detail{Reason: reason}
+	d.Retryable, _ = strconv.ParseBool(first(md, RETRYABLE_KEY))
+	if ms, err := strconv.ParseInt(first(md, RETRY_AFTER_KEY), 10, 64); err == nil && ms > 0 {
+		d.RetryAfter = time.Duration(ms) * time.Millisecond
+	}
+	return d, true
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,27 @@
+package errdetail
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMD(t *testing.T) {
+	d := New(QUEUE_FULL)
+	d.RetryAfter = 100 * time.Millisecond
+	got, ok := FromMD(d.MD())
+	if !ok || got != d || !got.Retryable {
+		t.Fatal(got, ok)
+	}
+
+	if got, ok := FromMD(New(KEY_NOT_FOUND).MD()); !ok || got.Retryable || got.RetryAfter != 0 {
+		t.Fatal(got, ok)
+	}
+	if _, ok := FromMD(metadata.MD{}); ok {
+		t.Fatal("detail from empty metadata")
+	}
+	if _, ok := FromMD(nil); ok {
+		t.Fatal("detail from nil metadata")
+	}
+}
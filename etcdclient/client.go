@@ -1,15 +1,46 @@
 package etcdclient
 
 import (
+	"time"
+
 	cli "gopkg.in/urfave/cli.v2"
 
 	log "github.com/Sirupsen/logrus"
 	etcdclient "github.com/coreos/etcd/client"
+	"github.com/coreos/etcd/clientv3"
+)
+
+const (
+	DIAL_TIMEOUT = 5 * time.Second
 )
 
 var client etcdclient.Client
+var client3 *clientv3.Client
 
+// Init creates the etcd v3 client
 func Init(c *cli.Context) {
+	// config
+	cfg := clientv3.Config{
+		Endpoints:   c.StringSlice("etcd-hosts"),
+		DialTimeout: DIAL_TIMEOUT,
+	}
+
+	// create client
+	etcdcli, err := clientv3.New(cfg)
+	if err != nil {
+		log.Panic(err)
+		return
+	}
+	client3 = etcdcli
+}
+
+// Client returns the etcd v3 client
+func Client() *clientv3.Client {
+	return client3
+}
+
+// InitV2 creates the deprecated etcd v2 client, only used to migrate v2 keys
+func InitV2(c *cli.Context) {
 	// config
 	cfg := etcdclient.Config{
 		Endpoints: c.StringSlice("etcd-hosts"),
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"snowflake/checkdigit"
+	"snowflake/errdetail"
+	pb "snowflake/proto"
+	"snowflake/store"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	FORMATS_DIR          = "_formats"         // hidden directory of sequence formats under pk-root
+	MAX_PADDING          = 19                 // digits of max int64
+	DEFAULT_FORMAT       = "{seq}"            // template of sequences without one
+	DEFAULT_RESET_FORMAT = "{bucket}-{seq:6}" // template of reset sequences without one
+)
+
+// seq_format renders values for NextFormatted, stored as json in <pk-root>/_formats/<name>,
+// the text is prefix + template + check digit, the check digit covers the digits of the template.
+type seq_format struct {
+	Template   string `json:"template,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	Padding    int    `json:"padding,omitempty"`
+	CheckDigit string `json:"check_digit,omitempty"`
+}
+
+// new_format converts and validates the format of a Create request
+func new_format(in *pb.Snowflake_Format) (*seq_format, error) {
+	f := &seq_format{Template: in.Template, Prefix: in.Prefix, Padding: int(in.Padding), CheckDigit: in.CheckDigit}
+	if f.Template != "" && !strings.Contains(f.Template, "{seq") {
+		return nil, errors.New("format without {seq}")
+	}
+	if f.Padding < 0 || f.Padding > MAX_PADDING {
+		return nil, fmt.Errorf("padding must be in range 0-%v", MAX_PADDING)
+	}
+	if _, err := f.render("", 0); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// template returns the template of the format, or the default one
+func (f *seq_format) template(reset bool) string {
+	switch {
+	case f.Template != "":
+		return f.Template
+	case reset:
+		return DEFAULT_RESET_FORMAT
+	}
+	return DEFAULT_FORMAT
+}
+
+// render returns the text of a value
+func (f *seq_format) render(bucket string, value int64) (string, error) {
+	body, err := expand(f.template(bucket != ""), bucket, value, f.Padding)
+	if err != nil {
+		return "", err
+	}
+
+	if f.CheckDigit != "" {
+		a, err := checkdigit.ByName(f.CheckDigit)
+		if err != nil {
+			return "", err
+		}
+		d, err := a.Compute(digits(body))
+		if err != nil {
+			return "", err
+		}
+		body += string(d)
+	}
+	return f.Prefix + body, nil
+}
+
+// verify checks a text by parsing the bucket and the value, and rendering them again,
+// which covers the prefix, the literals, the widths and the check digit.
+// width is the length of buckets, 0 for sequences never reset.
+func (f *seq_format) verify(text string, width int) (bool, error) {
+	if !strings.HasPrefix(text, f.Prefix) {
+		return false, nil
+	}
+	body := text[len(f.Prefix):]
+	if len(body) < 1 {
+		return false, nil
+	}
+	body = body[:len(body)-1] // the check digit
+
+	re, fields, err := pattern(f.template(width > 0), width)
+	if err != nil {
+		return false, err
+	}
+	m := re.FindStringSubmatch(body)
+	if m == nil {
+		return false, nil
+	}
+	var bucket string
+	var value int64
+	for i, field := range fields {
+		if field == "bucket" {
+			bucket = m[i+1]
+		} else if value, err = strconv.ParseInt(m[i+1], 10, 64); err != nil {
+			return false, nil
+		}
+	}
+
+	expected, err := f.render(bucket, value)
+	if err != nil {
+		return false, err
+	}
+	return expected == text, nil
+}
+
+// pattern returns a regexp matching the expansions of a template, and the fields of its groups,
+// {bucket} matches width digits, {seq} matches any decimal.
+func pattern(template string, width int) (*regexp.Regexp, []string, error) {
+	var buf bytes.Buffer
+	var fields []string
+	buf.WriteString("^")
+	t := template
+	for {
+		i := strings.IndexByte(t, '{')
+		if i < 0 {
+			buf.WriteString(regexp.QuoteMeta(t) + "$")
+			re, err := regexp.Compile(buf.String())
+			return re, fields, err
+		}
+		buf.WriteString(regexp.QuoteMeta(t[:i]))
+		j := strings.IndexByte(t[i:], '}')
+		if j < 0 {
+			return nil, nil, fmt.Errorf("unclosed { in format %q", template)
+		}
+
+		field := t[i+1 : i+j]
+		switch {
+		case field == "bucket":
+			fmt.Fprintf(&buf, `(\d{%d})`, width)
+			fields = append(fields, "bucket")
+		case field == "seq", strings.HasPrefix(field, "seq:"):
+			buf.WriteString(`(-?\d+)`)
+			fields = append(fields, "seq")
+		default:
+			return nil, nil, fmt.Errorf("unknown field {%v} in format %q", field, template)
+		}
+		t = t[i+j+1:]
+	}
+}
+
+// expand replaces {bucket}, {seq} and {seq:N} in a template, {seq} is zero-padded to padding digits
+func expand(template, bucket string, value int64, padding int) (string, error) {
+	var buf bytes.Buffer
+	t := template
+	for {
+		i := strings.IndexByte(t, '{')
+		if i < 0 {
+			buf.WriteString(t)
+			return buf.String(), nil
+		}
+		buf.WriteString(t[:i])
+		j := strings.IndexByte(t[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("unclosed { in format %q", template)
+		}
+
+		field := t[i+1 : i+j]
+		switch {
+		case field == "bucket":
+			buf.WriteString(bucket)
+		case field == "seq" && padding > 0:
+			fmt.Fprintf(&buf, "%0*d", padding, value)
+		case field == "seq":
+			buf.WriteString(strconv.FormatInt(value, 10))
+		case strings.HasPrefix(field, "seq:"):
+			width, err := strconv.Atoi(field[len("seq:"):])
+			if err != nil || width < 1 || width > MAX_PADDING {
+				return "", fmt.Errorf("padding of {%v} must be in range 1-%v", field, MAX_PADDING)
+			}
+			fmt.Fprintf(&buf, "%0*d", width, value)
+		default:
+			return "", fmt.Errorf("unknown field {%v} in format %q", field, template)
+		}
+		t = t[i+j+1:]
+	}
+}
+
+// get next value of a key, rendered with its format
+func (s *server) NextFormatted(ctx context.Context, in *pb.Snowflake_Key) (*pb.Snowflake_Formatted, error) {
+	value, _, bucket, err := s.incr(ctx, in.Name, 1, s.auto_create || in.AutoCreate)
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.load_format(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+	text, err := f.render(bucket, value)
+	if err != nil {
+		return nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed format of %v: %v", in.Name, err)
+	}
+	return &pb.Snowflake_Formatted{Value: value, Text: text, Bucket: bucket}, nil
+}
+
+// check a formatted value against the format of the sequence,
+// or only the check digit of the given algorithm, over a text of digits.
+func (s *server) Validate(ctx context.Context, in *pb.Snowflake_Validation) (*pb.Snowflake_Validity, error) {
+	if in.CheckDigit != "" {
+		a, err := checkdigit.ByName(in.CheckDigit)
+		if err != nil {
+			return nil, status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "%v", err)
+		}
+		return &pb.Snowflake_Validity{Valid: a.Verify(in.Text)}, nil
+	}
+
+	if _, err := s.seq_key(ctx, in.Name); err != nil {
+		return nil, err
+	}
+	f, err := s.load_format(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+	if f.CheckDigit == "" {
+		return nil, status_error(ctx, codes.FailedPrecondition, errdetail.NO_CHECK_DIGIT, "sequence %v has no check digit", in.Name)
+	}
+	opts, err := s.load_options(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, err := f.verify(in.Text, len(reset_layouts[opts.ResetPeriod]))
+	if err != nil {
+		return nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed format of %v: %v", in.Name, err)
+	}
+	return &pb.Snowflake_Validity{Valid: valid}, nil
+}
+
+// load_format reads the format of a sequence
+func (s *server) load_format(ctx context.Context, name string) (*seq_format, error) {
+	kv, err := s.store.Get(ctx, s.format_key(name))
+	if err == store.ErrNotFound {
+		return &seq_format{}, nil
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+
+	f := &seq_format{}
+	if err := json.Unmarshal(kv.Value, f); err != nil {
+		return nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed format of %v: %v", name, err)
+	}
+	return f, nil
+}
+
+// digits returns the decimal digits of s
+func digits(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String()
+}
+
+// format_kv encodes the format of a sequence
+func (s *server) format_kv(name string, f *seq_format) (*store.KV, error) {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return &store.KV{Key: s.format_key(name), Value: b}, nil
+}
+
+// format_key returns the key of a sequence format
+func (s *server) format_key(name string) string {
+	return s.pkroot + "/" + FORMATS_DIR + "/" + name
+}
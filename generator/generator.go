@@ -0,0 +1,259 @@
+// Package generator generates snowflake uuids in-process, independent of grpc and etcd.
+package generator
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"snowflake/uuid"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	ErrBeforeEpoch = errors.New("clock is earlier than epoch")
+	ErrOverflow    = errors.New("timestamp overflows layout")
+	ErrBeyondLimit = errors.New("timestamp reached the limit")
+)
+
+// Clock provides the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+// SystemClock reads the wall clock
+var SystemClock Clock = ClockFunc(time.Now)
+
+// MachineIDSource provides the machine id of a generator
+type MachineIDSource interface {
+	MachineID() (uint64, error)
+}
+
+// StaticMachineID is a fixed machine id
+type StaticMachineID uint64
+
+func (id StaticMachineID) MachineID() (uint64, error) { return uint64(id), nil }
+
+// Config of a Generator
+type Config struct {
+	Layout    uuid.Layout     // bit layout, default to uuid.Snowflake
+	Epoch     time.Time       // time which timestamps count from, default to 1970-01-01
+	Clock     Clock           // default to SystemClock
+	MachineID MachineIDSource // default to machine id 0
+	Policy    ClockPolicy     // what to do when the clock shifts backward, default to ClockWait
+	MaxWait   time.Duration   // max wait of ClockWait, 0 to wait unboundedly
+}
+
+// Generator generates unique uuids for one machine id,
+// it's safe for concurrent use, callers race on an atomic compare-and-swap instead of a lock.
+type Generator struct {
+	layout     uuid.Layout
+	epoch      time.Time
+	clock      Clock
+	machine_id uint64 // shifted machine id
+	policy     ClockPolicy
+	max_wait   time.Duration
+	stats      Stats // updated atomically
+	limit      int64 // timestamps must be less than it, 0 for no limit, updated atomically
+
+	// packed last timestamp and serial number, format:
+	//
+	// 0.................0		0........0
+	// timestamp			sn
+	state uint64
+}
+
+// New creates a Generator, the machine id is read once from cfg.MachineID.
+func New(cfg Config) (*Generator, error) {
+	g := &Generator{layout: cfg.Layout, epoch: cfg.Epoch, clock: cfg.Clock, policy: cfg.Policy, max_wait: cfg.MaxWait}
+	if g.layout == (uuid.Layout{}) {
+		g.layout = uuid.Snowflake
+	}
+	if err := g.layout.Validate(); err != nil {
+		return nil, err
+	}
+	if g.epoch.IsZero() {
+		g.epoch = time.Unix(0, 0)
+	}
+	if g.clock == nil {
+		g.clock = SystemClock
+	}
+
+	var id uint64
+	if cfg.MachineID != nil {
+		var err error
+		if id, err = cfg.MachineID.MachineID(); err != nil {
+			return nil, err
+		}
+	}
+	if id > g.layout.MachineIDMask() {
+		return nil, errors.New("machine id overflows layout")
+	}
+	g.machine_id = id << g.layout.MachineIDShift()
+
+	// validate the clock
+	if _, err := g.check_ts(g.ts()); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Layout returns the bit layout of generated uuids
+func (g *Generator) Layout() uuid.Layout { return g.layout }
+
+// Epoch returns the time which timestamps count from
+func (g *Generator) Epoch() time.Time { return g.epoch }
+
+// Last returns the time of the last issued timestamp,
+// which is ahead of the clock on ClockLogical.
+func (g *Generator) Last() time.Time {
+	ts := atomic.LoadUint64(&g.state) >> g.layout.SequenceBits
+	return g.epoch.Add(time.Duration(ts) * g.layout.Unit)
+}
+
+// SetLimit bounds the timestamps of following uuids to before t, eg: a persisted high-water,
+// uuids fail with ErrBeyondLimit once the clock reaches it, until it's raised.
+func (g *Generator) SetLimit(t time.Time) {
+	atomic.StoreInt64(&g.limit, int64(t.Sub(g.epoch)/g.layout.Unit))
+}
+
+// Stats returns the counters of clock shift handling
+func (g *Generator) Stats() Stats {
+	return Stats{
+		Waits:    atomic.LoadUint64(&g.stats.Waits),
+		Failures: atomic.LoadUint64(&g.stats.Failures),
+		Logical:  atomic.LoadUint64(&g.stats.Logical),
+	}
+}
+
+// Next generates an unique uuid
+func (g *Generator) Next() (uint64, error) {
+	ts, sn, _, err := g.reserve(1)
+	if err != nil {
+		return 0, err
+	}
+	return g.compose(ts, sn), nil
+}
+
+// NextN generates n uuids in a row,
+// spilling into following time units when the serial numbers run out.
+func (g *Generator) NextN(n int) ([]uint64, error) {
+	uuids := make([]uint64, 0, n)
+	for len(uuids) < n {
+		ts, sn, k, err := g.reserve(n - len(uuids))
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < uint64(k); i++ {
+			uuids = append(uuids, g.compose(ts, sn+i))
+		}
+	}
+	return uuids, nil
+}
+
+// reserve takes at most n consecutive serial numbers within one time unit,
+// returns the timestamp, the first serial number and the count reserved.
+func (g *Generator) reserve(n int) (uint64, uint64, int, error) {
+	sn_bits := g.layout.SequenceBits
+	sn_mask := g.layout.SequenceMask()
+	for {
+		old := atomic.LoadUint64(&g.state)
+		last_ts := int64(old >> sn_bits)
+		sn := old & sn_mask
+
+		// get a correct serial number
+		t := g.ts()
+		logical := false
+		if t < last_ts { // clock shift backward
+			switch {
+			case g.policy == ClockLogical:
+				atomic.AddUint64(&g.stats.Logical, 1)
+				t, logical = last_ts, true
+			case g.policy == ClockFail, g.max_wait > 0 && time.Duration(last_ts-t)*g.layout.Unit > g.max_wait:
+				atomic.AddUint64(&g.stats.Failures, 1)
+				return 0, 0, 0, ErrClockBackward
+			default:
+				atomic.AddUint64(&g.stats.Waits, 1)
+				log.Warn("clock shift happened, waiting until the clock moving to the next time unit.")
+				g.wait_ts(last_ts)
+				continue
+			}
+		}
+
+		var first, avail uint64
+		if t == last_ts { // same time unit
+			if sn == sn_mask { // serial number overflows
+				if !logical { // wait until next time unit
+					g.wait_ts(last_ts + 1)
+					continue
+				}
+				// move the logical clock forward
+				t, first, avail = last_ts+1, 0, sn_mask+1
+			} else {
+				first, avail = sn+1, sn_mask-sn
+			}
+		} else { // new time unit, serial number starts from 0
+			first, avail = 0, sn_mask+1
+		}
+
+		if limit := atomic.LoadInt64(&g.limit); limit != 0 && t >= limit {
+			return 0, 0, 0, ErrBeyondLimit
+		}
+		ts, err := g.check_ts(t)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		k := uint64(n)
+		if k > avail {
+			k = avail
+		}
+
+		// remember last timestamp and serial number
+		if atomic.CompareAndSwapUint64(&g.state, old, ts<<sn_bits|(first+k-1)) {
+			return ts, first, int(k), nil
+		}
+	}
+}
+
+// compose generates uuid, format:
+//
+// 0		0.................0		0..............0	0........0
+// 1-bit	timestamp			machine-id		sn
+//
+// the widths are defined by layout, default to 41bit, 10bit, 12bit.
+func (g *Generator) compose(ts, sn uint64) uint64 {
+	return ts<<g.layout.TimestampShift() | g.machine_id | sn
+}
+
+// check_ts makes sure t fits into the layout
+func (g *Generator) check_ts(t int64) (uint64, error) {
+	if t < 0 {
+		return 0, ErrBeforeEpoch
+	}
+	if uint64(t) > g.layout.TimestampMask() {
+		return 0, ErrOverflow
+	}
+	return uint64(t), nil
+}
+
+// wait_ts will wait untill last_ts
+func (g *Generator) wait_ts(last_ts int64) int64 {
+	t := g.ts()
+	for t < last_ts {
+		time.Sleep(time.Duration(last_ts-t) * g.layout.Unit)
+		t = g.ts()
+	}
+	return t
+}
+
+// get timestamp in layout time unit since epoch
+func (g *Generator) ts() int64 {
+	return int64(g.clock.Now().Sub(g.epoch) / g.layout.Unit)
+}
@@ -0,0 +1,322 @@
+package generator
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"snowflake/uuid"
+)
+
+// fake_clock advances one millisecond every tick calls
+type fake_clock struct {
+	mu    sync.Mutex
+	now   time.Time
+	calls int
+	tick  int
+}
+
+func (c *fake_clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.tick > 0 && c.calls%c.tick == 0 {
+		c.now = c.now.Add(time.Millisecond)
+	}
+	return c.now
+}
+
+func (c *fake_clock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func TestNext(t *testing.T) {
+	epoch := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fake_clock{now: epoch.Add(time.Hour)}
+	g, err := New(Config{Epoch: epoch, Clock: clock, MachineID: StaticMachineID(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint64(0); i < 3; i++ {
+		id, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := uuid.Snowflake.Decode(id, epoch)
+		if !d.Time.Equal(epoch.Add(time.Hour)) || d.MachineID != 7 || d.Sequence != i {
+			t.Fatalf("unexpected uuid %v", d)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	start := time.Now()
+	clock := &fake_clock{now: start}
+	g, err := New(Config{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetLimit(start.Add(time.Second))
+	if _, err := g.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clock reached the limit
+	clock.set(start.Add(time.Second))
+	if _, err := g.Next(); err != ErrBeyondLimit {
+		t.Fatalf("expect ErrBeyondLimit, got %v", err)
+	}
+	if _, err := g.NextN(2); err != ErrBeyondLimit {
+		t.Fatalf("expect ErrBeyondLimit, got %v", err)
+	}
+
+	// until it's raised
+	g.SetLimit(start.Add(2 * time.Second))
+	if _, err := g.Next(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNextNSpill(t *testing.T) {
+	clock := &fake_clock{now: time.Now(), tick: 100}
+	g, err := New(Config{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more than 4096 uuids must spill into the following milliseconds
+	ids, err := g.NextN(10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("uuids not increasing: %v %v", ids[i-1], ids[i])
+		}
+	}
+}
+
+func TestClockBackward(t *testing.T) {
+	now := time.Now()
+	clock := &fake_clock{now: now}
+	g, err := New(Config{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, _ := g.Next()
+
+	// rewind the clock, next uuid must still be greater
+	clock.set(now.Add(-5 * time.Millisecond))
+	clock.tick = 1
+	id, err := g.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id <= last {
+		t.Fatalf("uuid not increasing after clock shift: %v %v", last, id)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	now := time.Now()
+	if _, err := New(Config{Epoch: now.Add(time.Hour)}); err != ErrBeforeEpoch {
+		t.Fatalf("expect %v, got %v", ErrBeforeEpoch, err)
+	}
+	if _, err := New(Config{Layout: uuid.Layout{TimestampBits: 8, MachineIDBits: 10, SequenceBits: 12, Unit: time.Millisecond}}); err != ErrOverflow {
+		t.Fatalf("expect %v, got %v", ErrOverflow, err)
+	}
+	if _, err := New(Config{MachineID: StaticMachineID(1024)}); err == nil {
+		t.Fatal("machine id 1024 should be rejected")
+	}
+}
+
+func TestConcurrentNext(t *testing.T) {
+	g, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, count = 8, 20000
+	results := make(chan []uint64, workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			ids := make([]uint64, 0, count)
+			for j := 0; j < count; j++ {
+				if j%2 == 0 {
+					id, err := g.Next()
+					if err != nil {
+						t.Error(err)
+						break
+					}
+					ids = append(ids, id)
+				} else {
+					batch, err := g.NextN(3)
+					if err != nil {
+						t.Error(err)
+						break
+					}
+					ids = append(ids, batch...)
+				}
+			}
+			results <- ids
+		}(i)
+	}
+
+	seen := make(map[uint64]bool)
+	for i := 0; i < workers; i++ {
+		for _, id := range <-results {
+			if seen[id] {
+				t.Fatalf("duplicated uuid %v", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+// legacy_generator is the former design: a mutex or a single goroutine
+// serializing every request, kept to compare with the lock-free generator.
+type legacy_generator struct {
+	g       *Generator
+	sn      uint64
+	last_ts int64
+	mu      sync.Mutex
+	ch_proc chan chan uint64
+}
+
+func new_legacy_generator(b *testing.B) *legacy_generator {
+	g, err := New(Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	l := &legacy_generator{g: g, ch_proc: make(chan chan uint64, 1024)}
+	go func() {
+		for ret := range l.ch_proc {
+			ret <- l.next()
+		}
+	}()
+	return l
+}
+
+func (l *legacy_generator) next() uint64 {
+	t := l.g.ts()
+	if t < l.last_ts {
+		t = l.g.wait_ts(l.last_ts)
+	}
+	if l.last_ts == t {
+		l.sn = (l.sn + 1) & l.g.layout.SequenceMask()
+		if l.sn == 0 {
+			t = l.g.wait_ts(l.last_ts + 1)
+		}
+	} else {
+		l.sn = 0
+	}
+	l.last_ts = t
+	return l.g.compose(uint64(t), l.sn)
+}
+
+func BenchmarkNext(b *testing.B) {
+	g, err := New(Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g.Next()
+		}
+	})
+}
+
+func BenchmarkNextMutex(b *testing.B) {
+	l := new_legacy_generator(b)
+	defer close(l.ch_proc)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.mu.Lock()
+			l.next()
+			l.mu.Unlock()
+		}
+	})
+}
+
+func BenchmarkNextChannel(b *testing.B) {
+	l := new_legacy_generator(b)
+	defer close(l.ch_proc)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			req := make(chan uint64, 1)
+			l.ch_proc <- req
+			<-req
+		}
+	})
+}
+
+func TestClockPolicy(t *testing.T) {
+	now := time.Now()
+
+	// fail fast
+	clock := &fake_clock{now: now}
+	g, err := New(Config{Clock: clock, Policy: ClockFail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Next()
+	clock.set(now.Add(-time.Second))
+	if _, err := g.Next(); err != ErrClockBackward {
+		t.Fatalf("expect %v, got %v", ErrClockBackward, err)
+	}
+	if g.Stats().Failures != 1 {
+		t.Fatalf("unexpected stats %+v", g.Stats())
+	}
+
+	// bounded wait
+	clock = &fake_clock{now: now}
+	g, err = New(Config{Clock: clock, MaxWait: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Next()
+	clock.set(now.Add(-time.Second))
+	if _, err := g.Next(); err != ErrClockBackward {
+		t.Fatalf("expect %v, got %v", ErrClockBackward, err)
+	}
+
+	// logical clock keeps incrementing past the last timestamp
+	clock = &fake_clock{now: now}
+	g, err = New(Config{Clock: clock, Policy: ClockLogical})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, _ := g.Next()
+	clock.set(now.Add(-time.Second))
+	ids, err := g.NextN(10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if id <= last {
+			t.Fatalf("uuids not increasing: %v %v", last, id)
+		}
+		last = id
+	}
+	if g.Stats().Logical == 0 || g.Stats().Waits != 0 {
+		t.Fatalf("unexpected stats %+v", g.Stats())
+	}
+	if !g.Last().After(now) {
+		t.Fatalf("logical clock %v should be ahead of %v", g.Last(), now)
+	}
+}
+
+func TestParseClockPolicy(t *testing.T) {
+	for _, p := range []ClockPolicy{ClockWait, ClockFail, ClockLogical} {
+		v, err := ParseClockPolicy(p.String())
+		if err != nil || v != p {
+			t.Fatalf("policy %v: %v %v", p, v, err)
+		}
+	}
+	if _, err := ParseClockPolicy("skip"); err == nil {
+		t.Fatal("unknown policy should be rejected")
+	}
+}
@@ -0,0 +1,43 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrClockBackward = errors.New("clock shift backward")
+
+// ClockPolicy decides what to do when the clock shifts backward
+type ClockPolicy int
+
+const (
+	ClockWait    ClockPolicy = iota // wait until the clock catches up, bounded by Config.MaxWait
+	ClockFail                       // fail with ErrClockBackward immediately
+	ClockLogical                    // keep issuing on a logical clock incrementing past the last timestamp
+)
+
+var policy_names = []string{"wait", "fail", "logical"}
+
+// ParseClockPolicy accepts wait, fail or logical
+func ParseClockPolicy(v string) (ClockPolicy, error) {
+	for i, name := range policy_names {
+		if v == name {
+			return ClockPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown clock policy: %v", v)
+}
+
+func (p ClockPolicy) String() string {
+	if p >= 0 && int(p) < len(policy_names) {
+		return policy_names[p]
+	}
+	return fmt.Sprintf("ClockPolicy(%d)", int(p))
+}
+
+// Stats counts how clock shifts were handled
+type Stats struct {
+	Waits    uint64 // waited for the clock to catch up
+	Failures uint64 // failed with ErrClockBackward
+	Logical  uint64 // issued on the logical clock
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"snowflake/store"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	HW_INTERVAL = time.Second      // interval of persisting the high-water timestamp
+	HW_AHEAD    = 3 * time.Second  // how far the persisted high-water is ahead of the clock
+	HW_MAX_WAIT = 10 * time.Second // max wait on startup for the clock to pass the high-water
+)
+
+// the high-water timestamp is an upper bound of all timestamps issued by this machine id,
+// it's persisted ahead of the clock periodically into key <uuidkey>/high-water/<machine-id>,
+// and optionally into a local state file, a restarted process with a rewound clock
+// waits until the clock passes it, or refuses to start.
+// the generator never issues timestamps beyond the last persisted high-water, so uuids
+// fail instead of outrunning it while the store is unreachable.
+
+// wait_high_water blocks until the clock passes the persisted high-water
+func (s *server) wait_high_water(id int) error {
+	hw, err := s.load_high_water(id)
+	if err != nil {
+		return err
+	}
+
+	d := hw.Sub(time.Now())
+	if d <= 0 {
+		return nil
+	}
+	if d > HW_MAX_WAIT {
+		return fmt.Errorf("clock is %v behind the high-water %v of machine id %v", d, hw, id)
+	}
+	log.Warnf("clock is %v behind the high-water of machine id %v, waiting", d, id)
+	time.Sleep(d)
+	return nil
+}
+
+// load_high_water reads the greater high-water of the store and state file
+func (s *server) load_high_water(id int) (time.Time, error) {
+	var hw int64
+	kv, err := s.store.Get(context.Background(), s.high_water_key(id))
+	if err != nil && err != store.ErrNotFound {
+		return time.Time{}, err
+	}
+	if err == nil {
+		if hw, err = strconv.ParseInt(string(kv.Value), 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("malformed high-water %v: %v", s.high_water_key(id), err)
+		}
+	}
+
+	if s.statefile != "" {
+		b, err := ioutil.ReadFile(s.statefile)
+		if err == nil {
+			v, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("malformed state file %v: %v", s.statefile, err)
+			}
+			if v > hw {
+				hw = v
+			}
+		} else if !os.IsNotExist(err) {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(0, hw*int64(time.Millisecond)), nil
+}
+
+// save_high_water persists the clock plus HW_AHEAD,
+// or the logical clock of the generator if it's ahead, then raises the limit of the generator.
+func (s *server) save_high_water(id int) error {
+	now := time.Now()
+	if s.gen != nil && s.gen.Last().After(now) {
+		now = s.gen.Last()
+	}
+	ms := now.Add(HW_AHEAD).UnixNano() / int64(time.Millisecond)
+	v := strconv.FormatInt(ms, 10)
+	if err := s.store.Put(context.Background(), s.high_water_key(id), []byte(v)); err != nil {
+		return err
+	}
+
+	if s.statefile != "" {
+		// write and rename, never leaves a truncated file
+		tmp := s.statefile + ".tmp"
+		if err := ioutil.WriteFile(tmp, []byte(v), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, s.statefile); err != nil {
+			return err
+		}
+	}
+
+	if s.gen != nil {
+		s.gen.SetLimit(time.Unix(0, ms*int64(time.Millisecond)))
+	}
+	return nil
+}
+
+// high_water_task persists the high-water periodically
+func (s *server) high_water_task(id int) {
+	for {
+		<-time.After(HW_INTERVAL)
+		if err := s.save_high_water(id); err != nil {
+			log.Warn("save high-water:", err)
+		}
+	}
+}
+
+// high_water_key returns the key of the high-water of a machine id
+func (s *server) high_water_key(id int) string {
+	return s.uuidkey + "/high-water/" + strconv.Itoa(id)
+}
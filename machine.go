@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"snowflake/store"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const (
+	MACHINE_ID_TTL = 30 * time.Second // ttl of the lease of a claimed machine id
+)
+
+// claim_machine_id registers a machine id as a key attached to a lease under uuidkey,
+// format: <uuidkey>/<machine-id>
+// if id is negative, the first free id is taken.
+func (s *server) claim_machine_id(id int) (int, error) {
+	ctx := context.Background()
+	start := time.Now()
+	lease, err := s.store.Grant(ctx, MACHINE_ID_TTL)
+	if err != nil {
+		return 0, err
+	}
+	s.lease = lease
+
+	owner := []byte(machine_owner())
+	max_id := int(s.layout.MachineIDMask())
+	if id >= 0 {
+		if id > max_id {
+			return 0, fmt.Errorf("machine id %v out of range 0-%v", id, max_id)
+		}
+		if err := s.lease.Claim(ctx, s.machine_key(id), owner); err != nil {
+			if err == store.ErrExists {
+				return 0, fmt.Errorf("machine id %v unavailable", id)
+			}
+			return 0, err
+		}
+		s.renewed_at(start)
+		return id, nil
+	}
+
+	for id = 0; id <= max_id; id++ {
+		err := s.lease.Claim(ctx, s.machine_key(id), owner)
+		if err == nil {
+			s.renewed_at(start)
+			return id, nil
+		}
+		if err != store.ErrExists {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("all machine ids under %v are taken", s.uuidkey)
+}
+
+// machine_heartbeat keeps the lease of the claimed machine id alive,
+// the process exits if the id has been lost to another instance.
+func (s *server) machine_heartbeat(id int) {
+	for {
+		<-time.After(MACHINE_ID_TTL / 3)
+		if err := s.renew_machine_id(id); err != nil {
+			log.Warn("machine id heartbeat:", err)
+		}
+	}
+}
+
+// renew_machine_id renews the lease or takes the id back once the lease expired,
+// the expired lease is kept until a new one holds the id, so the next heartbeat retries.
+// each heartbeat is bounded by the interval of heartbeats.
+func (s *server) renew_machine_id(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), MACHINE_ID_TTL/3)
+	defer cancel()
+
+	start := time.Now()
+	err := s.lease.KeepAlive(ctx)
+	if err == nil {
+		s.renewed_at(start)
+	}
+	if err != store.ErrLeaseExpired {
+		return err
+	}
+
+	// lease expired, try to take the id back
+	log.Warn("machine id lease expired:", s.machine_key(id))
+	lease, err := s.store.Grant(ctx, MACHINE_ID_TTL)
+	if err != nil {
+		return err
+	}
+	owner := machine_owner()
+	err = lease.Claim(ctx, s.machine_key(id), []byte(owner))
+	if err == store.ErrExists {
+		// the key of the expired lease may outlive it for a while, the id is lost only to another owner
+		kv, err := s.store.Get(ctx, s.machine_key(id))
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+		if err == nil && string(kv.Value) == owner {
+			return fmt.Errorf("machine id %v still held by the expired lease", id)
+		}
+		log.Fatalf("machine id %v lost", id)
+	}
+	if err != nil {
+		return err
+	}
+	s.lease = lease
+	s.renewed_at(start)
+	return nil
+}
+
+// renewed_at records a successful renewal of the lease requested at t,
+// the lease lasts MACHINE_ID_TTL from a moment after t on the store.
+func (s *server) renewed_at(t time.Time) {
+	atomic.StoreInt64(&s.renewed, t.UnixNano())
+}
+
+// check_machine_id fails once the lease may have expired on the store,
+// another instance could have claimed the id and would generate the same uuids.
+func (s *server) check_machine_id(ctx context.Context) error {
+	since := time.Since(time.Unix(0, atomic.LoadInt64(&s.renewed)))
+	if since >= MACHINE_ID_TTL {
+		return machine_error(ctx, since)
+	}
+	return nil
+}
+
+// machine_key returns the key of a machine id
+func (s *server) machine_key(id int) string {
+	return s.uuidkey + "/" + strconv.Itoa(id)
+}
+
+// machine_owner describes this process, stored as the value of the machine id key
+func machine_owner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%v:%v", host, os.Getpid())
+}
@@ -10,6 +10,11 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"google.golang.org/grpc"
+
+	// drivers of the sql backend
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
@@ -25,6 +30,50 @@ func main() {
 				Value: ":10000",
 				Usage: "listening address:port",
 			},
+			&cli.StringFlag{
+				Name:  "backend",
+				Value: "etcd",
+				Usage: "storage backend, etcd, redis, sql, raft, memory or bolt",
+			},
+			&cli.StringFlag{
+				Name:  "bolt-path",
+				Value: "snowflake.db",
+				Usage: "database file of the bolt backend",
+			},
+			&cli.StringFlag{
+				Name:  "redis-addr",
+				Value: "127.0.0.1:6379",
+				Usage: "address of the redis backend",
+			},
+			&cli.StringFlag{
+				Name:  "sql-driver",
+				Value: "sqlite3",
+				Usage: "database driver of the sql backend, mysql, postgres or sqlite3",
+			},
+			&cli.StringFlag{
+				Name:  "sql-dsn",
+				Value: "snowflake.sqlite",
+				Usage: "data source name of the sql backend",
+			},
+			&cli.StringFlag{
+				Name:  "raft-id",
+				Value: "",
+				Usage: "member id in the raft group, defaults to raft-addr",
+			},
+			&cli.StringFlag{
+				Name:  "raft-addr",
+				Value: "127.0.0.1:7000",
+				Usage: "address for raft and commands forwarded to the leader",
+			},
+			&cli.StringFlag{
+				Name:  "raft-dir",
+				Value: "raft",
+				Usage: "directory of the raft log and snapshots",
+			},
+			&cli.StringSliceFlag{
+				Name:  "raft-peers",
+				Usage: "initial members of the raft group including this one, id=addr, empty for a single member",
+			},
 			&cli.StringSliceFlag{
 				Name:  "etcd-hosts",
 				Value: cli.NewStringSlice("http://127.0.0.1:2379"),
@@ -33,25 +82,96 @@ func main() {
 			&cli.IntFlag{
 				Name:  "machine-id",
 				Value: 0,
-				Usage: "snowflake machine id, 0-1023",
+				Usage: "snowflake machine id, 0-1023 for default layout, -1 to claim a free one through the store backend",
 			},
 			&cli.StringFlag{
 				Name:  "pk-root",
 				Value: "/seqs",
 				Usage: "path for auto increment primary keys",
 			},
+			&cli.IntFlag{
+				Name:  "segment-step",
+				Value: 0,
+				Usage: "reserve blocks of this size for Next and serve from memory, 0 to disable",
+			},
+			&cli.StringSliceFlag{
+				Name:  "segment-steps",
+				Usage: "per key block size of segment mode, name=step, overrides segment-step",
+			},
+			&cli.BoolFlag{
+				Name:  "auto-create",
+				Usage: "Next creates missing sequences from templates, instead of NotFound",
+			},
+			&cli.Int64Flag{
+				Name:  "auto-create-start",
+				Value: 1,
+				Usage: "first value of auto-created sequences, unless the template sets start",
+			},
+			&cli.StringSliceFlag{
+				Name:  "templates",
+				Usage: "auto-create templates written under pk-root/_templates, glob=json, eg: 'orders/*={\"start\":100000,\"increment\":1}'",
+			},
 			&cli.StringFlag{
 				Name:  "uuid-key",
 				Value: "/seqs/snowflake-uuid",
-				Usage: "uuid main key",
+				Usage: "directory for machine id registration",
+			},
+			&cli.StringFlag{
+				Name:  "epoch",
+				Value: "0",
+				Usage: "uuid timestamp epoch, RFC3339 or milliseconds since 1970",
+			},
+			&cli.StringFlag{
+				Name:  "clock-policy",
+				Value: "wait",
+				Usage: "what to do when the clock shifts backward, wait, fail or logical",
+			},
+			&cli.DurationFlag{
+				Name:  "max-clock-wait",
+				Value: 0,
+				Usage: "max wait for the clock to catch up with the wait policy, fail after that, 0 to wait unboundedly",
+			},
+			&cli.StringFlag{
+				Name:  "state-file",
+				Value: "",
+				Usage: "local file to persist the high-water timestamp, empty to use etcd only",
+			},
+			&cli.StringFlag{
+				Name:  "layout",
+				Value: "snowflake",
+				Usage: "uuid bit layout, snowflake, sonyflake, js53 or timestamp/machine-id/sequence/unit, eg: 39/16/8/10ms",
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:   "migrate",
+				Usage:  "copy sequences, options and high-water keys from the etcd v2 keys API into etcd v3",
+				Action: migrate,
 			},
 		},
 		Action: func(c *cli.Context) error {
 			log.Println("listen:", c.String("listen"))
+			log.Println("backend:", c.String("backend"))
+			log.Println("bolt-path:", c.String("bolt-path"))
+			log.Println("redis-addr:", c.String("redis-addr"))
+			log.Println("sql-driver:", c.String("sql-driver"))
+			log.Println("raft-addr:", c.String("raft-addr"))
+			log.Println("raft-dir:", c.String("raft-dir"))
+			log.Println("raft-peers:", c.StringSlice("raft-peers"))
 			log.Println("etcd-hosts:", c.StringSlice("etcd-hosts"))
 			log.Println("machine-id:", c.Int("machine-id"))
 			log.Println("pk-root:", c.String("pk-root"))
+			log.Println("segment-step:", c.Int("segment-step"))
+			log.Println("segment-steps:", c.StringSlice("segment-steps"))
+			log.Println("auto-create:", c.Bool("auto-create"))
+			log.Println("auto-create-start:", c.Int64("auto-create-start"))
+			log.Println("templates:", c.StringSlice("templates"))
 			log.Println("uuid-key:", c.String("uuid-key"))
+			log.Println("epoch:", c.String("epoch"))
+			log.Println("layout:", c.String("layout"))
+			log.Println("state-file:", c.String("state-file"))
+			log.Println("clock-policy:", c.String("clock-policy"))
+			log.Println("max-clock-wait:", c.Duration("max-clock-wait"))
 			// 监听
 			lis, err := net.Listen("tcp", c.String("listen"))
 			if err != nil {
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"snowflake/etcdclient"
+	"snowflake/store"
+	"strings"
+
+	cli "gopkg.in/urfave/cli.v2"
+
+	log "github.com/Sirupsen/logrus"
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// migrate copies sequences, options and high-water keys from the etcd v2 keys API into etcd v3,
+// existing v3 keys are never overwritten, so it's safe to run more than once.
+// the ephemeral machine id nodes are not copied, they are claimed again with leases.
+func migrate(c *cli.Context) error {
+	etcdclient.InitV2(c)
+	etcdclient.Init(c)
+
+	copied, skipped, err := migrate_keys(context.Background(), etcdclient.KeysAPI(), store.NewEtcd(etcdclient.Client()), c.String("pk-root"), c.String("uuid-key"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("migration done, %v copied, %v skipped", copied, skipped)
+	return nil
+}
+
+// migrate_keys copies the v2 keys under pkroot and the high-water keys under uuidkey into dst
+func migrate_keys(ctx context.Context, keys etcd.KeysAPI, dst store.Store, pkroot, uuidkey string) (copied, skipped int, err error) {
+	// hidden directories like options are under pk-root, high-water keys may be not
+	dirs := []string{pkroot}
+	if !strings.HasPrefix(uuidkey, pkroot+"/") {
+		dirs = append(dirs, uuidkey+"/high-water")
+	}
+
+	for _, dir := range dirs {
+		resp, err := keys.Get(ctx, dir, &etcd.GetOptions{Recursive: true})
+		if err != nil {
+			if etcd.IsKeyNotFound(err) {
+				continue
+			}
+			return copied, skipped, err
+		}
+
+		for _, node := range flatten(resp.Node) {
+			if node.Expiration != nil {
+				continue
+			}
+			err := dst.Create(ctx, node.Key, []byte(node.Value))
+			if err == store.ErrExists {
+				log.Warn("exists in v3, skipped:", node.Key)
+				skipped++
+				continue
+			}
+			if err != nil {
+				return copied, skipped, err
+			}
+			log.Println("migrated:", node.Key, node.Value)
+			copied++
+		}
+	}
+	return copied, skipped, nil
+}
+
+// flatten returns all value nodes in a v2 directory tree
+func flatten(node *etcd.Node) (nodes []*etcd.Node) {
+	if !node.Dir {
+		return []*etcd.Node{node}
+	}
+	for _, n := range node.Nodes {
+		nodes = append(nodes, flatten(n)...)
+	}
+	return
+}
@@ -42,7 +42,8 @@ func (*Snowflake) ProtoMessage()               {}
 func (*Snowflake) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
 type Snowflake_Key struct {
-	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Name       string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	AutoCreate bool   `protobuf:"varint,2,opt,name=auto_create" json:"auto_create,omitempty"`
 }
 
 func (m *Snowflake_Key) Reset()                    { *m = Snowflake_Key{} }
@@ -59,13 +60,146 @@ func (m *Snowflake_Value) String() string            { return proto1.CompactText
 func (*Snowflake_Value) ProtoMessage()               {}
 func (*Snowflake_Value) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
 
+type Snowflake_KeyCount struct {
+	Name       string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Count      int64  `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+	AutoCreate bool   `protobuf:"varint,3,opt,name=auto_create" json:"auto_create,omitempty"`
+}
+
+func (m *Snowflake_KeyCount) Reset()                    { *m = Snowflake_KeyCount{} }
+func (m *Snowflake_KeyCount) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_KeyCount) ProtoMessage()               {}
+func (*Snowflake_KeyCount) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 2} }
+
+type Snowflake_KeyValue struct {
+	Name    string             `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Value   int64              `protobuf:"varint,2,opt,name=value" json:"value,omitempty"`
+	Options *Snowflake_Options `protobuf:"bytes,3,opt,name=options" json:"options,omitempty"`
+	Format  *Snowflake_Format  `protobuf:"bytes,4,opt,name=format" json:"format,omitempty"`
+}
+
+func (m *Snowflake_KeyValue) Reset()                    { *m = Snowflake_KeyValue{} }
+func (m *Snowflake_KeyValue) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_KeyValue) ProtoMessage()               {}
+func (*Snowflake_KeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 3} }
+
+func (m *Snowflake_KeyValue) GetOptions() *Snowflake_Options {
+	if m != nil {
+		return m.Options
+	}
+	return nil
+}
+
+func (m *Snowflake_KeyValue) GetFormat() *Snowflake_Format {
+	if m != nil {
+		return m.Format
+	}
+	return nil
+}
+
+type Snowflake_Options struct {
+	Increment   int64  `protobuf:"varint,1,opt,name=increment" json:"increment,omitempty"`
+	MinValue    int64  `protobuf:"varint,2,opt,name=min_value" json:"min_value,omitempty"`
+	MaxValue    int64  `protobuf:"varint,3,opt,name=max_value" json:"max_value,omitempty"`
+	Cycle       bool   `protobuf:"varint,4,opt,name=cycle" json:"cycle,omitempty"`
+	ResetPeriod string `protobuf:"bytes,5,opt,name=reset_period" json:"reset_period,omitempty"`
+	Timezone    string `protobuf:"bytes,6,opt,name=timezone" json:"timezone,omitempty"`
+	HasMinValue bool   `protobuf:"varint,7,opt,name=has_min_value" json:"has_min_value,omitempty"`
+	HasMaxValue bool   `protobuf:"varint,8,opt,name=has_max_value" json:"has_max_value,omitempty"`
+}
+
+func (m *Snowflake_Options) Reset()                    { *m = Snowflake_Options{} }
+func (m *Snowflake_Options) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Options) ProtoMessage()               {}
+func (*Snowflake_Options) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 4} }
+
+type Snowflake_Format struct {
+	Template   string `protobuf:"bytes,1,opt,name=template" json:"template,omitempty"`
+	Prefix     string `protobuf:"bytes,2,opt,name=prefix" json:"prefix,omitempty"`
+	Padding    int32  `protobuf:"varint,3,opt,name=padding" json:"padding,omitempty"`
+	CheckDigit string `protobuf:"bytes,4,opt,name=check_digit" json:"check_digit,omitempty"`
+}
+
+func (m *Snowflake_Format) Reset()                    { *m = Snowflake_Format{} }
+func (m *Snowflake_Format) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Format) ProtoMessage()               {}
+func (*Snowflake_Format) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 5} }
+
+type Snowflake_Formatted struct {
+	Value  int64  `protobuf:"varint,1,opt,name=value" json:"value,omitempty"`
+	Text   string `protobuf:"bytes,2,opt,name=text" json:"text,omitempty"`
+	Bucket string `protobuf:"bytes,3,opt,name=bucket" json:"bucket,omitempty"`
+}
+
+func (m *Snowflake_Formatted) Reset()                    { *m = Snowflake_Formatted{} }
+func (m *Snowflake_Formatted) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Formatted) ProtoMessage()               {}
+func (*Snowflake_Formatted) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 6} }
+
+type Snowflake_Validation struct {
+	Name       string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Text       string `protobuf:"bytes,2,opt,name=text" json:"text,omitempty"`
+	CheckDigit string `protobuf:"bytes,3,opt,name=check_digit" json:"check_digit,omitempty"`
+}
+
+func (m *Snowflake_Validation) Reset()                    { *m = Snowflake_Validation{} }
+func (m *Snowflake_Validation) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Validation) ProtoMessage()               {}
+func (*Snowflake_Validation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 7} }
+
+type Snowflake_Validity struct {
+	Valid bool `protobuf:"varint,1,opt,name=valid" json:"valid,omitempty"`
+}
+
+func (m *Snowflake_Validity) Reset()                    { *m = Snowflake_Validity{} }
+func (m *Snowflake_Validity) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Validity) ProtoMessage()               {}
+func (*Snowflake_Validity) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 8} }
+
+type Snowflake_KeyValues struct {
+	Keys []*Snowflake_KeyValue `protobuf:"bytes,1,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *Snowflake_KeyValues) Reset()                    { *m = Snowflake_KeyValues{} }
+func (m *Snowflake_KeyValues) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_KeyValues) ProtoMessage()               {}
+func (*Snowflake_KeyValues) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 9} }
+
+func (m *Snowflake_KeyValues) GetKeys() []*Snowflake_KeyValue {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type Snowflake_CompareAndSet struct {
+	Name      string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Value     int64  `protobuf:"varint,2,opt,name=value" json:"value,omitempty"`
+	PrevValue int64  `protobuf:"varint,3,opt,name=prev_value" json:"prev_value,omitempty"`
+}
+
+func (m *Snowflake_CompareAndSet) Reset()                    { *m = Snowflake_CompareAndSet{} }
+func (m *Snowflake_CompareAndSet) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_CompareAndSet) ProtoMessage()               {}
+func (*Snowflake_CompareAndSet) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 10} }
+
+type Snowflake_Range struct {
+	Start int64 `protobuf:"varint,1,opt,name=start" json:"start,omitempty"`
+	End   int64 `protobuf:"varint,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *Snowflake_Range) Reset()                    { *m = Snowflake_Range{} }
+func (m *Snowflake_Range) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Range) ProtoMessage()               {}
+func (*Snowflake_Range) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 11} }
+
 type Snowflake_NullRequest struct {
 }
 
 func (m *Snowflake_NullRequest) Reset()                    { *m = Snowflake_NullRequest{} }
 func (m *Snowflake_NullRequest) String() string            { return proto1.CompactTextString(m) }
 func (*Snowflake_NullRequest) ProtoMessage()               {}
-func (*Snowflake_NullRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 2} }
+func (*Snowflake_NullRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 12} }
 
 type Snowflake_UUID struct {
 	Uuid uint64 `protobuf:"varint,1,opt,name=uuid" json:"uuid,omitempty"`
@@ -74,14 +208,79 @@ type Snowflake_UUID struct {
 func (m *Snowflake_UUID) Reset()                    { *m = Snowflake_UUID{} }
 func (m *Snowflake_UUID) String() string            { return proto1.CompactTextString(m) }
 func (*Snowflake_UUID) ProtoMessage()               {}
-func (*Snowflake_UUID) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 3} }
+func (*Snowflake_UUID) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 13} }
+
+type Snowflake_UUIDRequest struct {
+	Count int32 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *Snowflake_UUIDRequest) Reset()                    { *m = Snowflake_UUIDRequest{} }
+func (m *Snowflake_UUIDRequest) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_UUIDRequest) ProtoMessage()               {}
+func (*Snowflake_UUIDRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 14} }
+
+type Snowflake_UUIDs struct {
+	Uuids []uint64 `protobuf:"varint,1,rep,name=uuids" json:"uuids,omitempty"`
+}
+
+func (m *Snowflake_UUIDs) Reset()                    { *m = Snowflake_UUIDs{} }
+func (m *Snowflake_UUIDs) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_UUIDs) ProtoMessage()               {}
+func (*Snowflake_UUIDs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 15} }
+
+type Snowflake_Epoch struct {
+	Epoch int64 `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+}
+
+func (m *Snowflake_Epoch) Reset()                    { *m = Snowflake_Epoch{} }
+func (m *Snowflake_Epoch) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Epoch) ProtoMessage()               {}
+func (*Snowflake_Epoch) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 16} }
+
+type Snowflake_Layout struct {
+	TimestampBits uint32 `protobuf:"varint,1,opt,name=timestamp_bits" json:"timestamp_bits,omitempty"`
+	MachineIdBits uint32 `protobuf:"varint,2,opt,name=machine_id_bits" json:"machine_id_bits,omitempty"`
+	SequenceBits  uint32 `protobuf:"varint,3,opt,name=sequence_bits" json:"sequence_bits,omitempty"`
+	Unit          int64  `protobuf:"varint,4,opt,name=unit" json:"unit,omitempty"`
+}
+
+func (m *Snowflake_Layout) Reset()                    { *m = Snowflake_Layout{} }
+func (m *Snowflake_Layout) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_Layout) ProtoMessage()               {}
+func (*Snowflake_Layout) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 17} }
+
+type Snowflake_DecodedUUID struct {
+	Timestamp int64  `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	MachineId uint64 `protobuf:"varint,2,opt,name=machine_id" json:"machine_id,omitempty"`
+	Sequence  uint64 `protobuf:"varint,3,opt,name=sequence" json:"sequence,omitempty"`
+}
+
+func (m *Snowflake_DecodedUUID) Reset()                    { *m = Snowflake_DecodedUUID{} }
+func (m *Snowflake_DecodedUUID) String() string            { return proto1.CompactTextString(m) }
+func (*Snowflake_DecodedUUID) ProtoMessage()               {}
+func (*Snowflake_DecodedUUID) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 18} }
 
 func init() {
 	proto1.RegisterType((*Snowflake)(nil), "proto.Snowflake")
 	proto1.RegisterType((*Snowflake_Key)(nil), "proto.Snowflake.Key")
 	proto1.RegisterType((*Snowflake_Value)(nil), "proto.Snowflake.Value")
+	proto1.RegisterType((*Snowflake_KeyCount)(nil), "proto.Snowflake.KeyCount")
+	proto1.RegisterType((*Snowflake_KeyValue)(nil), "proto.Snowflake.KeyValue")
+	proto1.RegisterType((*Snowflake_Options)(nil), "proto.Snowflake.Options")
+	proto1.RegisterType((*Snowflake_Format)(nil), "proto.Snowflake.Format")
+	proto1.RegisterType((*Snowflake_Formatted)(nil), "proto.Snowflake.Formatted")
+	proto1.RegisterType((*Snowflake_Validation)(nil), "proto.Snowflake.Validation")
+	proto1.RegisterType((*Snowflake_Validity)(nil), "proto.Snowflake.Validity")
+	proto1.RegisterType((*Snowflake_KeyValues)(nil), "proto.Snowflake.KeyValues")
+	proto1.RegisterType((*Snowflake_CompareAndSet)(nil), "proto.Snowflake.CompareAndSet")
+	proto1.RegisterType((*Snowflake_Range)(nil), "proto.Snowflake.Range")
 	proto1.RegisterType((*Snowflake_NullRequest)(nil), "proto.Snowflake.NullRequest")
 	proto1.RegisterType((*Snowflake_UUID)(nil), "proto.Snowflake.UUID")
+	proto1.RegisterType((*Snowflake_UUIDRequest)(nil), "proto.Snowflake.UUIDRequest")
+	proto1.RegisterType((*Snowflake_UUIDs)(nil), "proto.Snowflake.UUIDs")
+	proto1.RegisterType((*Snowflake_Epoch)(nil), "proto.Snowflake.Epoch")
+	proto1.RegisterType((*Snowflake_Layout)(nil), "proto.Snowflake.Layout")
+	proto1.RegisterType((*Snowflake_DecodedUUID)(nil), "proto.Snowflake.DecodedUUID")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -96,7 +295,20 @@ const _ = grpc.SupportPackageIsVersion3
 
 type SnowflakeServiceClient interface {
 	Next(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Value, error)
+	NextN(ctx context.Context, in *Snowflake_KeyCount, opts ...grpc.CallOption) (*Snowflake_Range, error)
+	NextFormatted(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Formatted, error)
+	Validate(ctx context.Context, in *Snowflake_Validation, opts ...grpc.CallOption) (*Snowflake_Validity, error)
+	Create(ctx context.Context, in *Snowflake_KeyValue, opts ...grpc.CallOption) (*Snowflake_Value, error)
+	Delete(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Value, error)
+	List(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_KeyValues, error)
+	Get(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Value, error)
+	Set(ctx context.Context, in *Snowflake_CompareAndSet, opts ...grpc.CallOption) (*Snowflake_Value, error)
 	GetUUID(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_UUID, error)
+	GetUUIDs(ctx context.Context, in *Snowflake_UUIDRequest, opts ...grpc.CallOption) (*Snowflake_UUIDs, error)
+	StreamUUIDs(ctx context.Context, in *Snowflake_UUIDRequest, opts ...grpc.CallOption) (SnowflakeService_StreamUUIDsClient, error)
+	GetEpoch(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_Epoch, error)
+	GetLayout(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_Layout, error)
+	Decode(ctx context.Context, in *Snowflake_UUID, opts ...grpc.CallOption) (*Snowflake_DecodedUUID, error)
 }
 
 type snowflakeServiceClient struct {
@@ -116,6 +328,78 @@ func (c *snowflakeServiceClient) Next(ctx context.Context, in *Snowflake_Key, op
 	return out, nil
 }
 
+func (c *snowflakeServiceClient) NextN(ctx context.Context, in *Snowflake_KeyCount, opts ...grpc.CallOption) (*Snowflake_Range, error) {
+	out := new(Snowflake_Range)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/NextN", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) NextFormatted(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Formatted, error) {
+	out := new(Snowflake_Formatted)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/NextFormatted", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) Validate(ctx context.Context, in *Snowflake_Validation, opts ...grpc.CallOption) (*Snowflake_Validity, error) {
+	out := new(Snowflake_Validity)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/Validate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) Create(ctx context.Context, in *Snowflake_KeyValue, opts ...grpc.CallOption) (*Snowflake_Value, error) {
+	out := new(Snowflake_Value)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/Create", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) Delete(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Value, error) {
+	out := new(Snowflake_Value)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/Delete", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) List(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_KeyValues, error) {
+	out := new(Snowflake_KeyValues)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/List", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) Get(ctx context.Context, in *Snowflake_Key, opts ...grpc.CallOption) (*Snowflake_Value, error) {
+	out := new(Snowflake_Value)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/Get", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) Set(ctx context.Context, in *Snowflake_CompareAndSet, opts ...grpc.CallOption) (*Snowflake_Value, error) {
+	out := new(Snowflake_Value)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/Set", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *snowflakeServiceClient) GetUUID(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_UUID, error) {
 	out := new(Snowflake_UUID)
 	err := grpc.Invoke(ctx, "/proto.SnowflakeService/GetUUID", in, out, c.cc, opts...)
@@ -125,11 +409,92 @@ func (c *snowflakeServiceClient) GetUUID(ctx context.Context, in *Snowflake_Null
 	return out, nil
 }
 
+func (c *snowflakeServiceClient) GetUUIDs(ctx context.Context, in *Snowflake_UUIDRequest, opts ...grpc.CallOption) (*Snowflake_UUIDs, error) {
+	out := new(Snowflake_UUIDs)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/GetUUIDs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) StreamUUIDs(ctx context.Context, in *Snowflake_UUIDRequest, opts ...grpc.CallOption) (SnowflakeService_StreamUUIDsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_SnowflakeService_serviceDesc.Streams[0], c.cc, "/proto.SnowflakeService/StreamUUIDs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &snowflakeServiceStreamUUIDsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SnowflakeService_StreamUUIDsClient interface {
+	Recv() (*Snowflake_UUIDs, error)
+	grpc.ClientStream
+}
+
+type snowflakeServiceStreamUUIDsClient struct {
+	grpc.ClientStream
+}
+
+func (x *snowflakeServiceStreamUUIDsClient) Recv() (*Snowflake_UUIDs, error) {
+	m := new(Snowflake_UUIDs)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *snowflakeServiceClient) GetEpoch(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_Epoch, error) {
+	out := new(Snowflake_Epoch)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/GetEpoch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) GetLayout(ctx context.Context, in *Snowflake_NullRequest, opts ...grpc.CallOption) (*Snowflake_Layout, error) {
+	out := new(Snowflake_Layout)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/GetLayout", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snowflakeServiceClient) Decode(ctx context.Context, in *Snowflake_UUID, opts ...grpc.CallOption) (*Snowflake_DecodedUUID, error) {
+	out := new(Snowflake_DecodedUUID)
+	err := grpc.Invoke(ctx, "/proto.SnowflakeService/Decode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for SnowflakeService service
 
 type SnowflakeServiceServer interface {
 	Next(context.Context, *Snowflake_Key) (*Snowflake_Value, error)
+	NextN(context.Context, *Snowflake_KeyCount) (*Snowflake_Range, error)
+	NextFormatted(context.Context, *Snowflake_Key) (*Snowflake_Formatted, error)
+	Validate(context.Context, *Snowflake_Validation) (*Snowflake_Validity, error)
+	Create(context.Context, *Snowflake_KeyValue) (*Snowflake_Value, error)
+	Delete(context.Context, *Snowflake_Key) (*Snowflake_Value, error)
+	List(context.Context, *Snowflake_NullRequest) (*Snowflake_KeyValues, error)
+	Get(context.Context, *Snowflake_Key) (*Snowflake_Value, error)
+	Set(context.Context, *Snowflake_CompareAndSet) (*Snowflake_Value, error)
 	GetUUID(context.Context, *Snowflake_NullRequest) (*Snowflake_UUID, error)
+	GetUUIDs(context.Context, *Snowflake_UUIDRequest) (*Snowflake_UUIDs, error)
+	StreamUUIDs(*Snowflake_UUIDRequest, SnowflakeService_StreamUUIDsServer) error
+	GetEpoch(context.Context, *Snowflake_NullRequest) (*Snowflake_Epoch, error)
+	GetLayout(context.Context, *Snowflake_NullRequest) (*Snowflake_Layout, error)
+	Decode(context.Context, *Snowflake_UUID) (*Snowflake_DecodedUUID, error)
 }
 
 func RegisterSnowflakeServiceServer(s *grpc.Server, srv SnowflakeServiceServer) {
@@ -154,6 +519,150 @@ func _SnowflakeService_Next_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SnowflakeService_NextN_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_KeyCount)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).NextN(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/NextN",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).NextN(ctx, req.(*Snowflake_KeyCount))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_NextFormatted_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_Key)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).NextFormatted(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/NextFormatted",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).NextFormatted(ctx, req.(*Snowflake_Key))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_Validation)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/Validate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).Validate(ctx, req.(*Snowflake_Validation))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_KeyValue)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).Create(ctx, req.(*Snowflake_KeyValue))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_Key)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).Delete(ctx, req.(*Snowflake_Key))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_NullRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).List(ctx, req.(*Snowflake_NullRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_Key)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).Get(ctx, req.(*Snowflake_Key))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_CompareAndSet)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).Set(ctx, req.(*Snowflake_CompareAndSet))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _SnowflakeService_GetUUID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Snowflake_NullRequest)
 	if err := dec(in); err != nil {
@@ -172,6 +681,99 @@ func _SnowflakeService_GetUUID_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SnowflakeService_GetUUIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_UUIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).GetUUIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/GetUUIDs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).GetUUIDs(ctx, req.(*Snowflake_UUIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_StreamUUIDs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Snowflake_UUIDRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SnowflakeServiceServer).StreamUUIDs(m, &snowflakeServiceStreamUUIDsServer{stream})
+}
+
+type SnowflakeService_StreamUUIDsServer interface {
+	Send(*Snowflake_UUIDs) error
+	grpc.ServerStream
+}
+
+type snowflakeServiceStreamUUIDsServer struct {
+	grpc.ServerStream
+}
+
+func (x *snowflakeServiceStreamUUIDsServer) Send(m *Snowflake_UUIDs) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SnowflakeService_GetEpoch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_NullRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).GetEpoch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/GetEpoch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).GetEpoch(ctx, req.(*Snowflake_NullRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_GetLayout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_NullRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).GetLayout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/GetLayout",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).GetLayout(ctx, req.(*Snowflake_NullRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnowflakeService_Decode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snowflake_UUID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnowflakeServiceServer).Decode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.SnowflakeService/Decode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnowflakeServiceServer).Decode(ctx, req.(*Snowflake_UUID))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _SnowflakeService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "proto.SnowflakeService",
 	HandlerType: (*SnowflakeServiceServer)(nil),
@@ -180,29 +782,123 @@ var _SnowflakeService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Next",
 			Handler:    _SnowflakeService_Next_Handler,
 		},
+		{
+			MethodName: "NextN",
+			Handler:    _SnowflakeService_NextN_Handler,
+		},
+		{
+			MethodName: "NextFormatted",
+			Handler:    _SnowflakeService_NextFormatted_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _SnowflakeService_Validate_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _SnowflakeService_Create_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _SnowflakeService_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _SnowflakeService_List_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _SnowflakeService_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _SnowflakeService_Set_Handler,
+		},
 		{
 			MethodName: "GetUUID",
 			Handler:    _SnowflakeService_GetUUID_Handler,
 		},
+		{
+			MethodName: "GetUUIDs",
+			Handler:    _SnowflakeService_GetUUIDs_Handler,
+		},
+		{
+			MethodName: "GetEpoch",
+			Handler:    _SnowflakeService_GetEpoch_Handler,
+		},
+		{
+			MethodName: "GetLayout",
+			Handler:    _SnowflakeService_GetLayout_Handler,
+		},
+		{
+			MethodName: "Decode",
+			Handler:    _SnowflakeService_Decode_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUUIDs",
+			Handler:       _SnowflakeService_StreamUUIDs_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: fileDescriptor0,
 }
 
 func init() { proto1.RegisterFile("snowflake.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 186 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2f, 0xce, 0xcb, 0x2f,
-	0x4f, 0xcb, 0x49, 0xcc, 0x4e, 0xd5, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x05, 0x53, 0x4a,
-	0xb1, 0x5c, 0x9c, 0xc1, 0x30, 0x19, 0x29, 0x61, 0x2e, 0x66, 0xef, 0xd4, 0x4a, 0x21, 0x1e, 0x2e,
-	0x96, 0xbc, 0xc4, 0xdc, 0x54, 0x09, 0x46, 0x05, 0x46, 0x0d, 0x4e, 0x29, 0x31, 0x2e, 0xd6, 0xb0,
-	0xc4, 0x9c, 0xd2, 0x54, 0x21, 0x5e, 0x2e, 0xd6, 0x32, 0x10, 0x03, 0x2c, 0xce, 0x2c, 0xc5, 0xcb,
-	0xc5, 0xed, 0x57, 0x9a, 0x93, 0x13, 0x94, 0x5a, 0x58, 0x9a, 0x5a, 0x5c, 0x22, 0x25, 0xc2, 0xc5,
-	0x12, 0x1a, 0xea, 0xe9, 0x02, 0xd2, 0x5c, 0x5a, 0x9a, 0x99, 0x02, 0x56, 0xc4, 0x62, 0xd4, 0xc1,
-	0xc8, 0x25, 0x00, 0x37, 0x3f, 0x38, 0xb5, 0xa8, 0x2c, 0x33, 0x39, 0x55, 0xc8, 0x84, 0x8b, 0xc5,
-	0x2f, 0xb5, 0xa2, 0x44, 0x48, 0x04, 0xe2, 0x14, 0x3d, 0xb8, 0x02, 0x3d, 0xef, 0xd4, 0x4a, 0x29,
-	0x31, 0x0c, 0x51, 0x88, 0xf5, 0x76, 0x5c, 0xec, 0xee, 0xa9, 0x25, 0x60, 0x3b, 0x64, 0x30, 0x94,
-	0x20, 0xbb, 0x44, 0x14, 0x43, 0x16, 0xa4, 0x29, 0x89, 0x0d, 0x2c, 0x6a, 0x0c, 0x08, 0x00, 0x00,
-	0xff, 0xff, 0x7b, 0x2f, 0xb3, 0xe6, 0x0a, 0x01, 0x00, 0x00,
+	// 817 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x55, 0xdb, 0x6e, 0xdb, 0x46,
+	0x10, 0x85, 0xc2, 0x8b, 0xc8, 0x61, 0x68, 0x27, 0x5b, 0xdb, 0x91, 0xb7, 0x41, 0x61, 0xb4, 0x0f,
+	0x75, 0x5f, 0x8c, 0xc2, 0x09, 0x8a, 0xa2, 0xad, 0x0b, 0x27, 0x76, 0x62, 0x14, 0x09, 0xdc, 0x22,
+	0x46, 0xf2, 0x58, 0x61, 0x4d, 0x8e, 0xad, 0x85, 0x78, 0x2b, 0xb9, 0x74, 0xa5, 0xf6, 0x83, 0xfa,
+	0x23, 0xfd, 0x80, 0x7e, 0x52, 0xb1, 0xb3, 0xd4, 0x25, 0x22, 0x25, 0xc0, 0xc8, 0x93, 0x85, 0x33,
+	0x7b, 0xce, 0xdc, 0xce, 0xd0, 0xb0, 0x5d, 0x65, 0xf9, 0x9f, 0x37, 0x89, 0x18, 0xe3, 0x51, 0x51,
+	0xe6, 0x2a, 0x67, 0x0e, 0xfd, 0xf9, 0xf2, 0x3f, 0x0f, 0xfc, 0xab, 0x59, 0x88, 0x1f, 0x82, 0xf5,
+	0x06, 0xa7, 0xec, 0x21, 0xd8, 0x99, 0x48, 0x71, 0xd0, 0x3b, 0xe8, 0x1d, 0xfa, 0xec, 0x33, 0x08,
+	0x44, 0xad, 0xf2, 0x61, 0x54, 0xa2, 0x50, 0x38, 0x78, 0x70, 0xd0, 0x3b, 0xf4, 0xf8, 0x1e, 0x38,
+	0x1f, 0x44, 0x52, 0x23, 0x0b, 0xc1, 0xb9, 0xd3, 0x3f, 0xe8, 0xb1, 0xc5, 0x7f, 0x02, 0xef, 0x0d,
+	0x4e, 0xcf, 0xf2, 0x3a, 0x53, 0x2b, 0x32, 0x21, 0x38, 0x91, 0x86, 0x49, 0xc0, 0x5a, 0x55, 0xb5,
+	0x48, 0xf5, 0x6f, 0x62, 0x1b, 0xe1, 0x16, 0xdb, 0xa4, 0x31, 0xec, 0x6f, 0xa0, 0x9f, 0x17, 0x4a,
+	0xe6, 0x59, 0x45, 0xcc, 0xe0, 0x78, 0x60, 0xda, 0x3a, 0x9a, 0xf7, 0x72, 0xf4, 0xab, 0x89, 0xb3,
+	0xaf, 0xc1, 0xbd, 0xc9, 0xcb, 0x54, 0xa8, 0x81, 0x4d, 0x2f, 0x9f, 0xb4, 0x5e, 0xbe, 0xa6, 0x30,
+	0xff, 0xa7, 0x07, 0xfd, 0x19, 0xe9, 0x31, 0xf8, 0x32, 0x8b, 0x4a, 0x4c, 0x31, 0x53, 0xa6, 0x33,
+	0x0d, 0xa5, 0x32, 0x1b, 0x2e, 0x57, 0xa1, 0x21, 0x31, 0x69, 0x20, 0x8b, 0x20, 0xdd, 0xe5, 0x34,
+	0x4a, 0x90, 0x92, 0x79, 0x6c, 0x07, 0x1e, 0x96, 0x58, 0xa1, 0x1a, 0x16, 0x58, 0xca, 0x3c, 0x1e,
+	0x38, 0xd4, 0xcc, 0x23, 0xf0, 0x94, 0x4c, 0xf1, 0xaf, 0x3c, 0xc3, 0x81, 0x4b, 0xc8, 0x2e, 0x84,
+	0x23, 0x51, 0x0d, 0x17, 0x09, 0xfa, 0x44, 0x9f, 0xc1, 0xf3, 0x24, 0x1e, 0x8d, 0xe9, 0x37, 0x70,
+	0x4d, 0xcd, 0xa4, 0x84, 0x69, 0x91, 0xe8, 0x11, 0x9a, 0x41, 0x6d, 0x81, 0x5b, 0x94, 0x78, 0x23,
+	0x27, 0x54, 0xa3, 0xcf, 0xb6, 0xa1, 0x5f, 0x88, 0x38, 0x96, 0xd9, 0x2d, 0x55, 0xe8, 0xe8, 0xc1,
+	0x47, 0x23, 0x8c, 0xc6, 0xc3, 0x58, 0xde, 0x4a, 0x33, 0x14, 0x9f, 0x7f, 0x0f, 0xbe, 0x51, 0x54,
+	0x18, 0xaf, 0xac, 0x54, 0x2f, 0x42, 0xe1, 0x44, 0x35, 0x7a, 0x5b, 0xe0, 0x5e, 0xd7, 0xd1, 0x18,
+	0x15, 0xc9, 0xf9, 0xfc, 0x04, 0xe0, 0x83, 0x48, 0x64, 0x2c, 0xf4, 0xe0, 0x56, 0x96, 0xf6, 0x31,
+	0x73, 0x25, 0xb1, 0xa1, 0xef, 0x83, 0x47, 0x74, 0xa9, 0xa6, 0x4d, 0x5e, 0x19, 0x13, 0xdb, 0xe3,
+	0xcf, 0xc1, 0x9f, 0x99, 0x41, 0x6f, 0xd1, 0x1e, 0xe3, 0xb4, 0x1a, 0xf4, 0x0e, 0xac, 0xc3, 0xe0,
+	0x78, 0xbf, 0xb5, 0xc3, 0xd9, 0x4b, 0x7e, 0x0a, 0xe1, 0x59, 0x9e, 0x16, 0xa2, 0xc4, 0x17, 0x59,
+	0x7c, 0x85, 0x6a, 0xb3, 0x8f, 0x18, 0x40, 0x51, 0xe2, 0xdd, 0xf2, 0x0a, 0xf9, 0x57, 0xe0, 0xbc,
+	0x13, 0xd9, 0x2d, 0x59, 0xbb, 0x52, 0xa2, 0x9c, 0x19, 0x20, 0x00, 0x0b, 0xb3, 0xd8, 0x10, 0x79,
+	0x08, 0xc1, 0x65, 0x9d, 0x24, 0xef, 0xf0, 0x8f, 0x1a, 0x2b, 0xc5, 0x77, 0xc0, 0x7e, 0xff, 0xfe,
+	0x97, 0x73, 0x9d, 0xac, 0xae, 0x9b, 0x0e, 0x6c, 0xfe, 0x14, 0x02, 0x8d, 0x36, 0x8f, 0x16, 0x17,
+	0xa0, 0xa3, 0x8e, 0x3e, 0x21, 0x1d, 0xad, 0x34, 0xae, 0x49, 0xa6, 0x39, 0x5b, 0xe3, 0xaf, 0x8a,
+	0x3c, 0x1a, 0x69, 0x1c, 0xf5, 0x8f, 0xe6, 0xb4, 0x7e, 0x07, 0xf7, 0xad, 0x98, 0xe6, 0xb5, 0x62,
+	0x7b, 0xb0, 0xa5, 0xfd, 0x53, 0x29, 0x91, 0x16, 0xc3, 0x6b, 0xa9, 0x2a, 0x7a, 0x11, 0xb2, 0x27,
+	0xb0, 0x9d, 0x8a, 0x68, 0x24, 0x33, 0x1c, 0xca, 0xd8, 0x04, 0x1e, 0x50, 0x60, 0x17, 0xc2, 0x4a,
+	0x17, 0x91, 0x45, 0x68, 0x60, 0x8b, 0x60, 0x5d, 0x6d, 0xd6, 0x78, 0xc0, 0xe2, 0xaf, 0x21, 0x38,
+	0xc7, 0x28, 0x8f, 0x31, 0xa6, 0x56, 0x1e, 0x83, 0x3f, 0x4f, 0xd2, 0x4c, 0x80, 0x01, 0x2c, 0xf4,
+	0x49, 0xda, 0xd6, 0x0e, 0x9c, 0x49, 0x93, 0xaa, 0x7d, 0xfc, 0x6f, 0x1f, 0x1e, 0xcd, 0x17, 0x73,
+	0x85, 0xe5, 0x9d, 0x8c, 0x90, 0x3d, 0x07, 0xfb, 0x12, 0x27, 0x8a, 0xed, 0x74, 0x6d, 0x8e, 0xef,
+	0xb5, 0x50, 0xf3, 0x0d, 0xf8, 0x01, 0x1c, 0xcd, 0xba, 0x64, 0x9d, 0x0b, 0xa7, 0xaf, 0x4c, 0x07,
+	0xd7, 0x6c, 0xef, 0x05, 0x84, 0x9a, 0xbb, 0xb0, 0x75, 0x77, 0x6a, 0xbe, 0xe6, 0x73, 0xa0, 0x19,
+	0x2f, 0x1b, 0x73, 0x0a, 0x85, 0xec, 0xf3, 0xae, 0x12, 0x1b, 0xdb, 0xf3, 0xfd, 0xee, 0xa0, 0x36,
+	0xf5, 0x8f, 0xe0, 0x9e, 0xd1, 0x27, 0x8e, 0x6d, 0x30, 0xed, 0xba, 0xfe, 0xbf, 0x03, 0xf7, 0x1c,
+	0x13, 0x54, 0x78, 0xcf, 0xb9, 0x9d, 0x82, 0xfd, 0x56, 0x56, 0x8a, 0x3d, 0x6d, 0xc5, 0x97, 0x4d,
+	0xcb, 0xd7, 0x16, 0x54, 0xb1, 0x67, 0x60, 0x5d, 0xe0, 0x7d, 0xd7, 0x75, 0x02, 0x96, 0xbe, 0xb8,
+	0x2f, 0x5a, 0xe1, 0x8f, 0x2e, 0x72, 0x2d, 0xfd, 0x67, 0xe8, 0x5f, 0xa0, 0x22, 0xf3, 0x6d, 0x2e,
+	0x7c, 0xb7, 0x15, 0x25, 0xd2, 0x29, 0x78, 0x0d, 0xbf, 0xea, 0x10, 0x58, 0xba, 0x44, 0xbe, 0xd7,
+	0x19, 0xad, 0xd8, 0x2b, 0x08, 0xae, 0x54, 0x89, 0x22, 0xfd, 0x04, 0x91, 0x6f, 0x7b, 0x4d, 0x21,
+	0xe6, 0x88, 0x37, 0x77, 0xd2, 0xd6, 0x30, 0xac, 0x97, 0xe0, 0x5f, 0xa0, 0x6a, 0xce, 0x7d, 0xb3,
+	0x44, 0xfb, 0xff, 0x59, 0x43, 0x3b, 0x01, 0xd7, 0xdc, 0x33, 0xeb, 0x9e, 0x17, 0x6f, 0xeb, 0x2e,
+	0xdd, 0xff, 0xb5, 0x4b, 0xc1, 0x67, 0xff, 0x0f, 0x00, 0x3d, 0x60, 0x1d, 0x95, 0x3a, 0x08, 0x00,
+	0x00,
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cli "gopkg.in/urfave/cli.v2"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	SEGMENT_PREFETCH = 0.8         // prefetch the next block when the current one is 80% used
+	SEGMENT_BACKOFF  = time.Second // delay of the next prefetch after a failed one
+)
+
+// segment serves a sequence from memory,
+// the server reserves a block of values with one CompareAndSwap, and prefetches
+// the next block asynchronously, values of unused blocks are lost on restart.
+// a failed prefetch is retried after SEGMENT_BACKOFF, or by the synchronous reservation
+// once the block runs out, which returns the error.
+// blocks of cycling sequences restart from the other bound when they don't fit,
+// skipping the values left before the bound.
+type segment struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	step     int64     // number of values in a block
+	next     int64     // next value to serve
+	left     int64     // values left in the current block
+	inc      int64     // increment of the current block
+	prefetch [2]int64  // first and last value of the prefetched block
+	has_next bool      // prefetch is valid
+	loading  bool      // a block is being reserved
+	retry    time.Time // no prefetch before it, after a failed one
+	bypass   bool      // reset sequences are never served from memory, blocks would outlive periods
+}
+
+// init_segments parses segment mode flags
+func (s *server) init_segments(c *cli.Context) error {
+	s.segments = make(map[string]*segment)
+	s.segment_steps = make(map[string]int64)
+	s.segment_default = int64(c.Int("segment-step"))
+	if s.segment_default < 0 {
+		return fmt.Errorf("negative segment step: %v", s.segment_default)
+	}
+
+	// format: name=step
+	for _, v := range c.StringSlice("segment-steps") {
+		i := strings.LastIndex(v, "=")
+		if i <= 0 {
+			return fmt.Errorf("malformed segment step: %v", v)
+		}
+		step, err := strconv.ParseInt(v[i+1:], 10, 64)
+		if err != nil || step < 0 {
+			return fmt.Errorf("malformed segment step: %v", v)
+		}
+		s.segment_steps[v[:i]] = step
+	}
+	return nil
+}
+
+// segment_step returns the block size of a key, 0 if segment mode is disabled
+func (s *server) segment_step(name string) int64 {
+	if step, ok := s.segment_steps[name]; ok {
+		return step
+	}
+	return s.segment_default
+}
+
+// segment_next serves the next value of a key from its segment,
+// ctx bounds the synchronous reservation of a block, auto creates a missing sequence.
+func (s *server) segment_next(ctx context.Context, name string, step int64, auto bool) (int64, error) {
+	if _, err := s.seq_key(ctx, name); err != nil {
+		return 0, err
+	}
+	s.muSegments.Lock()
+	seg, ok := s.segments[name]
+	s.muSegments.Unlock()
+	if !ok {
+		opts, err := s.load_options(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		s.muSegments.Lock()
+		if seg, ok = s.segments[name]; !ok {
+			seg = &segment{step: step, bypass: opts.ResetPeriod != ""}
+			seg.cond = sync.NewCond(&seg.mu)
+			s.segments[name] = seg
+		}
+		s.muSegments.Unlock()
+	}
+	if seg.bypass {
+		value, _, _, err := s.incr(ctx, name, 1, auto)
+		return value, err
+	}
+
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	for seg.left == 0 {
+		// switch to the prefetched block
+		if seg.has_next {
+			seg.use(seg.prefetch[0], seg.prefetch[1])
+			seg.has_next = false
+			break
+		}
+
+		// wait for the block being reserved
+		if seg.loading {
+			seg.cond.Wait()
+			continue
+		}
+
+		// reserve a block synchronously
+		seg.loading = true
+		seg.mu.Unlock()
+		first, last, _, err := s.incr(ctx, name, seg.step, auto)
+		tail := grpc.Code(err) == codes.OutOfRange
+		if tail {
+			// fewer values than a block are left within the bounds, serve them one by one
+			first, _, _, err = s.incr(ctx, name, 1, auto)
+		}
+		seg.mu.Lock()
+		seg.loading = false
+		seg.cond.Broadcast()
+		if err != nil {
+			return 0, err
+		}
+		if tail {
+			return first, nil
+		}
+		seg.use(first, last)
+	}
+
+	value := seg.next
+	seg.left--
+	if seg.left > 0 {
+		seg.next += seg.inc
+	}
+
+	// prefetch the next block
+	if !seg.has_next && !seg.loading && float64(seg.step-seg.left) >= float64(seg.step)*SEGMENT_PREFETCH && time.Now().After(seg.retry) {
+		seg.loading = true
+		go s.segment_prefetch(name, seg)
+	}
+	return value, nil
+}
+
+// use switches to a block
+func (seg *segment) use(first, last int64) {
+	seg.next, seg.left, seg.inc = first, seg.step, 0
+	if seg.step > 1 {
+		seg.inc = (last - first) / (seg.step - 1)
+	}
+}
+
+// segment_prefetch reserves the next block of a segment
+func (s *server) segment_prefetch(name string, seg *segment) {
+	first, last, _, err := s.incr(context.Background(), name, seg.step, false)
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	seg.loading = false
+	seg.cond.Broadcast()
+	if err != nil {
+		seg.retry = time.Now().Add(SEGMENT_BACKOFF)
+		if grpc.Code(err) != codes.OutOfRange {
+			log.Warnf("segment prefetch of %v: %v", name, err)
+		}
+		return
+	}
+	seg.prefetch, seg.has_next = [2]int64{first, last}, true
+}
+
+// drop_segment discards the in-memory segment of a key, after it's been created, set or deleted
+func (s *server) drop_segment(name string) {
+	s.muSegments.Lock()
+	delete(s.segments, name)
+	s.muSegments.Unlock()
+}
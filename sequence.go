@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"snowflake/errdetail"
+	pb "snowflake/proto"
+	"snowflake/store"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	OPTIONS_DIR = "_options" // hidden directory of sequence options under pk-root
+	BUCKETS_DIR = "_buckets" // hidden directory of the period counters of reset sequences under pk-root
+)
+
+// periods of reset sequences, and the layouts of their buckets
+var reset_layouts = map[string]string{
+	"day":   "20060102",
+	"month": "200601",
+	"year":  "2006",
+}
+
+var (
+	zones   = make(map[string]*time.Location) // loaded timezones
+	muZones sync.Mutex
+)
+
+// seq_options mirrors postgresql CREATE SEQUENCE, stored as json in <pk-root>/_options/<name>,
+// sequences without options increase by 1 without bounds.
+type seq_options struct {
+	Increment   int64  `json:"increment"`
+	MinValue    int64  `json:"min_value"`
+	MaxValue    int64  `json:"max_value"`
+	Cycle       bool   `json:"cycle"`
+	ResetPeriod string `json:"reset_period,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+var default_options = seq_options{Increment: 1, MinValue: math.MinInt64, MaxValue: math.MaxInt64}
+
+// new_options converts and validates options of a Create request
+func new_options(in *pb.Snowflake_Options) (*seq_options, error) {
+	o := &seq_options{
+		Increment:   in.Increment,
+		MinValue:    in.MinValue,
+		MaxValue:    in.MaxValue,
+		Cycle:       in.Cycle,
+		ResetPeriod: in.ResetPeriod,
+		Timezone:    in.Timezone,
+	}
+	if o.Increment == 0 {
+		o.Increment = 1
+	}
+	// each side is unbounded unless set, 0 is only a bound with has_min_value or has_max_value
+	if o.MinValue == 0 && !in.HasMinValue {
+		o.MinValue = default_options.MinValue
+	}
+	if o.MaxValue == 0 && !in.HasMaxValue {
+		o.MaxValue = default_options.MaxValue
+	}
+
+	if o.Increment == math.MinInt64 {
+		return nil, errors.New("increment out of range")
+	}
+	if o.MinValue >= o.MaxValue {
+		return nil, errors.New("min_value must be less than max_value")
+	}
+	if uint64(abs(o.Increment)) > uint64(o.MaxValue-o.MinValue) { // the distance overflows int64, not uint64
+		return nil, errors.New("increment exceeds the range of min_value and max_value")
+	}
+	if _, ok := reset_layouts[o.ResetPeriod]; !ok && o.ResetPeriod != "" {
+		return nil, fmt.Errorf("unknown reset period: %v", o.ResetPeriod)
+	}
+	if o.Timezone != "" {
+		if o.ResetPeriod == "" {
+			return nil, errors.New("timezone without reset_period")
+		}
+		if _, err := location(o.Timezone); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// start_in_range checks the initial value of a sequence, which is either within the bounds,
+// or one increment before the first value.
+func (o *seq_options) start_in_range(value int64) bool {
+	first, ok := store.Add(value, o.Increment)
+	return o.contains(value) || ok && o.contains(first)
+}
+
+func (o *seq_options) contains(v int64) bool {
+	return v >= o.MinValue && v <= o.MaxValue
+}
+
+// bucket returns the period of t for reset sequences, eg: 20161018, empty for others
+func (o *seq_options) bucket(t time.Time) (string, error) {
+	if o.ResetPeriod == "" {
+		return "", nil
+	}
+	layout, ok := reset_layouts[o.ResetPeriod]
+	if !ok {
+		return "", fmt.Errorf("unknown reset period: %v", o.ResetPeriod)
+	}
+	loc, err := location(o.Timezone)
+	if err != nil {
+		return "", err
+	}
+	return t.In(loc).Format(layout), nil
+}
+
+// advance returns the first and the last of n values following prev
+func (o *seq_options) advance(prev, n int64) (int64, int64, bool) {
+	return o.bounds().Advance(prev, n)
+}
+
+// bounds converts the options for stores advancing sequences natively
+func (o *seq_options) bounds() store.Bounds {
+	return store.Bounds{Increment: o.Increment, MinValue: o.MinValue, MaxValue: o.MaxValue, Cycle: o.Cycle}
+}
+
+// load_options reads the options of a sequence
+func (s *server) load_options(ctx context.Context, name string) (*seq_options, error) {
+	kv, err := s.store.Get(ctx, s.options_key(name))
+	if err == store.ErrNotFound {
+		o := default_options
+		return &o, nil
+	}
+	if err != nil {
+		log.Error(err)
+		return nil, store_error(ctx, err)
+	}
+
+	o := &seq_options{}
+	if err := json.Unmarshal(kv.Value, o); err != nil {
+		return nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed options of %v: %v", name, err)
+	}
+	return o, nil
+}
+
+// options_kv encodes the options of a sequence
+func (s *server) options_kv(name string, o *seq_options) (*store.KV, error) {
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return &store.KV{Key: s.options_key(name), Value: b}, nil
+}
+
+// options_key returns the key of sequence options
+func (s *server) options_key(name string) string {
+	return s.pkroot + "/" + OPTIONS_DIR + "/" + name
+}
+
+// bucket_key returns the key of the counter of a period, <pk-root>/_buckets/<name>/<bucket>
+func (s *server) bucket_key(name, bucket string) string {
+	return s.pkroot + "/" + BUCKETS_DIR + "/" + name + "/" + bucket
+}
+
+// is_hidden checks whether a sequence name contains hidden path segments, which are not sequences
+func is_hidden(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if strings.HasPrefix(seg, "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// location loads a timezone once, UTC for empty names
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	muZones.Lock()
+	defer muZones.Unlock()
+	if loc, ok := zones[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	zones[name] = loc
+	return loc, nil
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -1,137 +1,466 @@
 package main
 
 import (
-	"errors"
+	"expvar"
 	"fmt"
+	"math/rand"
+	"snowflake/errdetail"
 	"snowflake/etcdclient"
+	"snowflake/generator"
 	pb "snowflake/proto"
+	"snowflake/store"
+	"snowflake/uuid"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	cli "gopkg.in/urfave/cli.v2"
 
 	log "github.com/Sirupsen/logrus"
-	etcd "github.com/coreos/etcd/client"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
 )
 
 const (
 	BACKOFF    = 100  // max backoff delay millisecond
-	CONCURRENT = 128  // max concurrent connections to etcd
-	UUID_QUEUE = 1024 // uuid process queue
-)
-
-const (
-	TS_MASK         = 0x1FFFFFFFFFF // 41bit
-	SN_MASK         = 0xFFF         // 12bit
-	MACHINE_ID_MASK = 0x3FF         // 10bit
+	MAX_RETRY  = 16   // max CompareAndSwap retries of Next, Aborted after that
+	CONCURRENT = 128  // max concurrent sequence requests to the store
+	QUEUE_SIZE = 1024 // max sequence requests waiting for the store, ResourceExhausted beyond
+	UUID_BATCH = 4096 // max uuids in one batch
 )
 
 type server struct {
-	pkroot     string
-	uuidkey    string
-	machine_id uint64 // 10-bit machine id
-	ch_proc    chan chan uint64
-	muNext     sync.Mutex
+	pkroot    string
+	uuidkey   string
+	statefile string               // local file of the high-water timestamp
+	store     store.Store          // storage of sequences, machine ids and high-water
+	lease     store.Lease          // lease of the claimed machine id
+	renewed   int64                // unix nano when the last successful renewal of lease was requested
+	layout    uuid.Layout          // bit layout of uuid
+	gen       *generator.Generator // uuid generator
+
+	segment_steps   map[string]int64 // per key block size of segment mode
+	segment_default int64            // default block size, 0 to disable segment mode
+	segments        map[string]*segment
+	muSegments      sync.Mutex
+
+	slots   chan struct{} // requests in flight to the store, at most CONCURRENT
+	waiting int32         // requests waiting for a slot
+
+	auto_create bool  // Next creates missing sequences from templates
+	auto_start  int64 // first value of auto-created sequences without a template start
+
+	now func() time.Time // clock of reset periods
 }
 
 func (s *server) init(c *cli.Context) {
-	etcdclient.Init(c)
-	s.ch_proc = make(chan chan uint64, UUID_QUEUE)
-	// shifted machine id
-	s.machine_id = (uint64(c.Int("machine-id")) & MACHINE_ID_MASK) << 12
+	st, err := open_store(c)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	s.store = st
 	s.pkroot = c.String("pk-root")
 	s.uuidkey = c.String("uuid-key")
-	go s.uuid_task()
+	s.statefile = c.String("state-file")
+	s.slots = make(chan struct{}, CONCURRENT)
+	s.now = time.Now
+
+	// segment mode
+	if err := s.init_segments(c); err != nil {
+		log.Fatalln(err)
+	}
+
+	// auto-create
+	if err := s.init_templates(c); err != nil {
+		log.Fatalln(err)
+	}
+
+	// uuid layout
+	layout, err := uuid.ParseLayout(c.String("layout"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	s.layout = layout
+
+	// custom epoch
+	epoch, err := parse_epoch(c.String("epoch"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// claim a machine id from the store
+	id, err := s.claim_machine_id(c.Int("machine-id"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Info("machine id claimed: ", id)
+	go s.machine_heartbeat(id)
+
+	// guard against clock rollback across restarts
+	if err := s.wait_high_water(id); err != nil {
+		log.Fatalln(err)
+	}
+
+	// uuid generator
+	policy, err := generator.ParseClockPolicy(c.String("clock-policy"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	s.gen, err = generator.New(generator.Config{
+		Layout:    s.layout,
+		Epoch:     time.Unix(0, epoch*int64(time.Millisecond)),
+		MachineID: generator.StaticMachineID(id),
+		Policy:    policy,
+		MaxWait:   c.Duration("max-clock-wait"),
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// the generator issues nothing beyond the persisted high-water
+	if err := s.save_high_water(id); err != nil {
+		log.Fatalln(err)
+	}
+	go s.high_water_task(id)
+
+	// clock shift metrics, exported at /debug/vars
+	expvar.Publish("clock_backward", expvar.Func(func() interface{} { return s.gen.Stats() }))
 }
 
 // get next value of a key, like auto-increment in mysql
 func (s *server) Next(ctx context.Context, in *pb.Snowflake_Key) (*pb.Snowflake_Value, error) {
-	s.muNext.Lock()
-	defer s.muNext.Unlock()
-	client := etcdclient.KeysAPI()
-	key := s.pkroot + "/" + in.Name
-	for {
+	// served from memory in segment mode
+	if step := s.segment_step(in.Name); step > 0 {
+		value, err := s.segment_next(ctx, in.Name, step, s.auto_create || in.AutoCreate)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.Snowflake_Value{Value: value}, nil
+	}
+
+	value, _, _, err := s.incr(ctx, in.Name, 1, s.auto_create || in.AutoCreate)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Snowflake_Value{Value: value}, nil
+}
+
+// allocate count consecutive values of a key with one CompareAndSwap
+func (s *server) NextN(ctx context.Context, in *pb.Snowflake_KeyCount) (*pb.Snowflake_Range, error) {
+	if in.Count <= 0 {
+		return nil, status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "count must be positive")
+	}
+
+	first, last, _, err := s.incr(ctx, in.Name, in.Count, s.auto_create || in.AutoCreate)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Snowflake_Range{Start: first, End: last}, nil
+}
+
+// incr advances a key by n values with CompareAndSwap, following the options of the sequence,
+// returns the first and the last value, and the bucket of reset sequences.
+// stores advancing sequences natively do it in one round trip, otherwise
+// concurrent calls on the same key retry with jittered backoff, different keys never block each other.
+// it gives up when ctx is done, or with Aborted after MAX_RETRY conflicts.
+// missing sequences are created first with auto, see create_missing.
+// machine ids, high-water and hidden keys are not sequences, InvalidArgument.
+func (s *server) incr(ctx context.Context, name string, n int64, auto bool) (int64, int64, string, error) {
+	if _, err := s.seq_key(ctx, name); err != nil {
+		return 0, 0, "", err
+	}
+	if err := s.acquire(ctx); err != nil {
+		return 0, 0, "", err
+	}
+	defer s.release()
+
+	first, last, bucket, err := s.advance(ctx, name, n)
+	if err == store.ErrNotFound && auto {
+		if err := s.create_missing(ctx, name); err != nil {
+			return 0, 0, "", err
+		}
+		first, last, bucket, err = s.advance(ctx, name, n)
+	}
+	if err == store.ErrNotFound {
+		return 0, 0, "", not_found_error(ctx, name)
+	}
+	return first, last, bucket, err
+}
+
+// advance does the work of incr, store.ErrNotFound for missing sequences.
+// reset sequences count in <pk-root>/_buckets/<name>/<bucket>, created on first use from the value of the sequence.
+func (s *server) advance(ctx context.Context, name string, n int64) (int64, int64, string, error) {
+	opts, err := s.load_options(ctx, name)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	bucket, err := opts.bucket(s.now())
+	if err != nil {
+		return 0, 0, "", status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed options of %v: %v", name, err)
+	}
+
+	key := s.pkroot + "/" + name
+	if bucket == "" {
+		first, last, err := s.advance_key(ctx, name, key, n, opts)
+		return first, last, "", err
+	}
+	bkey := s.bucket_key(name, bucket)
+	first, last, err := s.advance_key(ctx, name, bkey, n, opts)
+	if err == store.ErrNotFound {
+		if err := s.open_bucket(ctx, key, bkey); err != nil {
+			return 0, 0, "", err
+		}
+		first, last, err = s.advance_key(ctx, name, bkey, n, opts)
+	}
+	return first, last, bucket, err
+}
+
+// open_bucket creates the counter of a period, starting from the value of the sequence,
+// store.ErrNotFound if the sequence is missing.
+func (s *server) open_bucket(ctx context.Context, key, bucket_key string) error {
+	kv, err := s.store.Get(ctx, key)
+	if err == store.ErrNotFound {
+		return err
+	}
+	if err != nil {
+		log.Error(err)
+		return store_error(ctx, err)
+	}
+
+	err = s.store.Create(ctx, bucket_key, kv.Value)
+	if err != nil && err != store.ErrExists {
+		log.Error(err)
+		return store_error(ctx, err)
+	}
+	return nil
+}
+
+// advance_key advances the counter of a sequence at key
+func (s *server) advance_key(ctx context.Context, name, key string, n int64, opts *seq_options) (int64, int64, error) {
+	if c, ok := s.store.(store.Counter); ok {
+		first, last, err := c.Incr(ctx, key, n, opts.bounds())
+		switch err {
+		case nil:
+			return first, last, nil
+		case store.ErrNotFound:
+			return 0, 0, store.ErrNotFound
+		case store.ErrOutOfRange:
+			return 0, 0, status_error(ctx, codes.OutOfRange, errdetail.OUT_OF_RANGE, "sequence %v reached its bound", name)
+		case store.ErrConflict:
+			return 0, 0, conflict_error(ctx, name)
+		}
+		log.Error(err)
+		return 0, 0, store_error(ctx, err)
+	}
+
+	for retry := uint(0); retry < MAX_RETRY; retry++ {
 		// get the key
-		resp, err := client.Get(context.Background(), key, nil)
+		kv, err := s.store.Get(ctx, key)
+		if err == store.ErrNotFound {
+			return 0, 0, store.ErrNotFound
+		}
 		if err != nil {
 			log.Error(err)
-			return nil, errors.New("Key not exists, need to create first")
+			return 0, 0, store_error(ctx, err)
 		}
 
-		// get prevValue & prevIndex
-		prevValue, err := strconv.Atoi(resp.Node.Value)
+		// get prevValue
+		prevValue, err := strconv.ParseInt(string(kv.Value), 10, 64)
 		if err != nil {
 			log.Error(err)
-			return nil, errors.New("marlformed value")
+			return 0, 0, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed value of %v: %q", name, kv.Value)
+		}
+
+		// apply increment, bounds and cycle
+		first, last, ok := opts.advance(prevValue, n)
+		if !ok {
+			return 0, 0, status_error(ctx, codes.OutOfRange, errdetail.OUT_OF_RANGE, "sequence %v reached its bound", name)
 		}
-		prevIndex := resp.Node.ModifiedIndex
 
 		// CompareAndSwap
-		resp, err = client.Set(context.Background(), key, fmt.Sprint(prevValue+1), &etcd.SetOptions{PrevIndex: prevIndex})
-		if err != nil {
-			log.Warn(err)
+		err = s.store.CompareAndSwap(ctx, key, []byte(fmt.Sprint(last)), kv.Revision)
+		if err == store.ErrConflict {
+			if err := backoff(ctx, retry); err != nil {
+				return 0, 0, store_error(ctx, err)
+			}
 			continue
 		}
-		return &pb.Snowflake_Value{int64(prevValue + 1)}, nil
+		if err == store.ErrNotFound {
+			return 0, 0, store.ErrNotFound
+		}
+		if err != nil {
+			log.Error(err)
+			return 0, 0, store_error(ctx, err)
+		}
+		return first, last, nil
+	}
+
+	return 0, 0, conflict_error(ctx, name)
+}
+
+// acquire takes a slot of the store, waiting in the queue while CONCURRENT requests are in flight,
+// ResourceExhausted if QUEUE_SIZE requests are waiting already.
+func (s *server) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if atomic.AddInt32(&s.waiting, 1) > QUEUE_SIZE {
+		atomic.AddInt32(&s.waiting, -1)
+		return queue_error(ctx)
+	}
+	defer atomic.AddInt32(&s.waiting, -1)
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return store_error(ctx, ctx.Err())
 	}
 }
 
+// release returns the slot taken by acquire
+func (s *server) release() {
+	<-s.slots
+}
+
 // generate an unique uuid
-func (s *server) GetUUID(context.Context, *pb.Snowflake_NullRequest) (*pb.Snowflake_UUID, error) {
-	req := make(chan uint64, 1)
-	s.ch_proc <- req
-	return &pb.Snowflake_UUID{<-req}, nil
+func (s *server) GetUUID(ctx context.Context, in *pb.Snowflake_NullRequest) (*pb.Snowflake_UUID, error) {
+	if err := s.check_machine_id(ctx); err != nil {
+		return nil, err
+	}
+	id, err := s.gen.Next()
+	if err != nil {
+		log.Error(err)
+		return nil, uuid_error(ctx, err)
+	}
+	return &pb.Snowflake_UUID{Uuid: id}, nil
+}
+
+// generate a batch of unique uuids
+func (s *server) GetUUIDs(ctx context.Context, in *pb.Snowflake_UUIDRequest) (*pb.Snowflake_UUIDs, error) {
+	if in.Count <= 0 || in.Count > UUID_BATCH {
+		return nil, status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "count must be in range 1-%v", UUID_BATCH)
+	}
+	if err := s.check_machine_id(ctx); err != nil {
+		return nil, err
+	}
+	uuids, err := s.gen.NextN(int(in.Count))
+	if err != nil {
+		log.Error(err)
+		return nil, uuid_error(ctx, err)
+	}
+	return &pb.Snowflake_UUIDs{Uuids: uuids}, nil
 }
 
-// uuid generator
-func (s *server) uuid_task() {
-	var sn uint64     // 12-bit serial no
-	var last_ts int64 // last timestamp
+// keep pushing batches of unique uuids until the client goes away,
+// Send blocks while the flow control window is full, so uuids are generated as the client consumes.
+func (s *server) StreamUUIDs(in *pb.Snowflake_UUIDRequest, stream pb.SnowflakeService_StreamUUIDsServer) error {
+	ctx := stream.Context()
+	if in.Count <= 0 || in.Count > UUID_BATCH {
+		return status_error(ctx, codes.InvalidArgument, errdetail.INVALID_ARGUMENT, "count must be in range 1-%v", UUID_BATCH)
+	}
+
 	for {
-		ret := <-s.ch_proc
-		// get a correct serial number
-		t := ts()
-		if t < last_ts { // clock shift backward
-			log.Warn("clock shift happened, waiting until the clock moving to the next millisecond.")
-			t = s.wait_ms(last_ts)
-		}
-
-		if last_ts == t { // same millisecond
-			sn = (sn + 1) & SN_MASK
-			if sn == 0 { // serial number overflows, wait until next ms
-				t = s.wait_ms(last_ts)
-			}
-		} else { // new millsecond, reset serial number to 0
-			sn = 0
+		select {
+		case <-ctx.Done():
+			return store_error(ctx, ctx.Err())
+		default:
 		}
-		// remember last timestamp
-		last_ts = t
 
-		// generate uuid, format:
-		//
-		// 0		0.................0		0..............0	0........0
-		// 1-bit	41bit timestamp			10bit machine-id	12bit sn
-		var uuid uint64
-		uuid |= (uint64(t) & TS_MASK) << 22
-		uuid |= s.machine_id
-		uuid |= sn
-		ret <- uuid
+		if err := s.check_machine_id(ctx); err != nil {
+			return err
+		}
+		uuids, err := s.gen.NextN(int(in.Count))
+		if err != nil {
+			log.Error(err)
+			return uuid_error(ctx, err)
+		}
+		if err := stream.Send(&pb.Snowflake_UUIDs{Uuids: uuids}); err != nil {
+			return err
+		}
+	}
+}
+
+// split an uuid into timestamp, machine id and serial number
+func (s *server) Decode(ctx context.Context, in *pb.Snowflake_UUID) (*pb.Snowflake_DecodedUUID, error) {
+	id := s.layout.Decode(in.Uuid, s.gen.Epoch())
+	return &pb.Snowflake_DecodedUUID{
+		Timestamp: id.Time.UnixNano() / int64(time.Millisecond),
+		MachineId: id.MachineID,
+		Sequence:  id.Sequence,
+	}, nil
+}
+
+// get the epoch of uuid timestamps
+func (s *server) GetEpoch(context.Context, *pb.Snowflake_NullRequest) (*pb.Snowflake_Epoch, error) {
+	return &pb.Snowflake_Epoch{Epoch: s.gen.Epoch().UnixNano() / int64(time.Millisecond)}, nil
+}
+
+// get the bit layout of uuids
+func (s *server) GetLayout(context.Context, *pb.Snowflake_NullRequest) (*pb.Snowflake_Layout, error) {
+	return &pb.Snowflake_Layout{
+		TimestampBits: uint32(s.layout.TimestampBits),
+		MachineIdBits: uint32(s.layout.MachineIDBits),
+		SequenceBits:  uint32(s.layout.SequenceBits),
+		Unit:          int64(s.layout.Unit),
+	}, nil
+}
+
+// open_store opens the storage backend selected by --backend
+func open_store(c *cli.Context) (store.Store, error) {
+	switch c.String("backend") {
+	case "etcd":
+		etcdclient.Init(c)
+		return store.NewEtcd(etcdclient.Client()), nil
+	case "memory":
+		return store.NewMemory(), nil
+	case "bolt":
+		return store.NewBolt(c.String("bolt-path"))
+	case "redis":
+		return store.NewRedis(c.String("redis-addr")), nil
+	case "sql":
+		return store.NewSQL(c.String("sql-driver"), c.String("sql-dsn"))
+	case "raft":
+		id := c.String("raft-id")
+		if id == "" {
+			id = c.String("raft-addr")
+		}
+		return store.NewRaft(id, c.String("raft-addr"), c.String("raft-dir"), c.StringSlice("raft-peers"))
 	}
+	return nil, fmt.Errorf("unknown backend: %v", c.String("backend"))
 }
 
-// wait_ms will wait untill last_ts
-func (s *server) wait_ms(last_ts int64) int64 {
-	t := ts()
-	for t < last_ts {
-		time.Sleep(time.Duration(last_ts-t) * time.Millisecond)
-		t = ts()
+// backoff sleeps a random duration up to min(2^retry, BACKOFF) milliseconds,
+// returns the error of ctx if it's done earlier.
+func backoff(ctx context.Context, retry uint) error {
+	max := int64(BACKOFF)
+	if retry < 7 && 1<<retry < max {
+		max = 1 << retry
+	}
+	select {
+	case <-time.After(time.Duration(rand.Int63n(max)+1) * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return t
 }
 
-// get timestamp
-func ts() int64 {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+// parse_epoch accepts either RFC3339 or milliseconds since 1970
+func parse_epoch(v string) (int64, error) {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return 0, fmt.Errorf("malformed epoch: %v", v)
+		}
+		ms = t.UnixNano() / int64(time.Millisecond)
+	}
+
+	if ms < 0 {
+		return 0, fmt.Errorf("epoch before 1970: %v", v)
+	}
+	return ms, nil
 }
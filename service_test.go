@@ -1,11 +1,27 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"snowflake/errdetail"
+	"snowflake/generator"
 	pb "snowflake/proto"
+	"snowflake/store"
+	"snowflake/uuid"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	etcd "github.com/coreos/etcd/client"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -82,3 +98,1195 @@ func BenchmarkSnowflakeUUID(b *testing.B) {
 		}
 	}
 }
+
+func TestSnowflakeEpoch(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// Contact the server and print out its response.
+	r, err := c.GetEpoch(context.Background(), &pb.Snowflake_NullRequest{})
+	if err != nil {
+		t.Fatalf("could not get epoch: %v", err)
+	}
+	t.Log(r.Epoch)
+}
+
+func TestSnowflakeLayout(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// Contact the server and print out its response.
+	r, err := c.GetLayout(context.Background(), &pb.Snowflake_NullRequest{})
+	if err != nil {
+		t.Fatalf("could not get layout: %v", err)
+	}
+	t.Log(r)
+}
+
+func TestSnowflakeUUIDs(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// Contact the server and check the uniqueness of the batch.
+	r, err := c.GetUUIDs(context.Background(), &pb.Snowflake_UUIDRequest{Count: 4096})
+	if err != nil {
+		t.Fatalf("could not get uuids: %v", err)
+	}
+	if len(r.Uuids) != 4096 {
+		t.Fatalf("expect 4096 uuids, got %v", len(r.Uuids))
+	}
+	for i := 1; i < len(r.Uuids); i++ {
+		if r.Uuids[i] <= r.Uuids[i-1] {
+			t.Fatalf("uuids not increasing: %v %v", r.Uuids[i-1], r.Uuids[i])
+		}
+	}
+}
+
+func BenchmarkSnowflakeUUIDs(b *testing.B) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		b.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	for i := 0; i < b.N; i++ {
+		// Contact the server and print out its response.
+		_, err := c.GetUUIDs(context.Background(), &pb.Snowflake_UUIDRequest{Count: 1024})
+		if err != nil {
+			b.Fatalf("could not get uuids: %v", err)
+		}
+	}
+}
+
+func TestSnowflakeStreamUUIDs(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// Receive a few batches, then cancel the stream.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamUUIDs(ctx, &pb.Snowflake_UUIDRequest{Count: 128})
+	if err != nil {
+		t.Fatalf("could not open stream: %v", err)
+	}
+
+	var last uint64
+	for i := 0; i < 16; i++ {
+		r, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("could not receive uuids: %v", err)
+		}
+		for _, uuid := range r.Uuids {
+			if uuid <= last {
+				t.Fatalf("uuids not increasing: %v %v", last, uuid)
+			}
+			last = uuid
+		}
+	}
+}
+
+func BenchmarkSnowflakeStreamUUIDs(b *testing.B) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		b.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamUUIDs(ctx, &pb.Snowflake_UUIDRequest{Count: 1})
+	if err != nil {
+		b.Fatalf("could not open stream: %v", err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		if _, err := stream.Recv(); err != nil {
+			b.Fatalf("could not receive uuids: %v", err)
+		}
+	}
+}
+
+func TestSnowflakeDecode(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// Generate an uuid and take it apart.
+	r, err := c.GetUUID(context.Background(), &pb.Snowflake_NullRequest{})
+	if err != nil {
+		t.Fatalf("could not get uuid: %v", err)
+	}
+	d, err := c.Decode(context.Background(), r)
+	if err != nil {
+		t.Fatalf("could not decode uuid: %v", err)
+	}
+	t.Log(d)
+}
+
+func TestSnowflakeNextN(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// Contact the server and check the range.
+	r, err := c.NextN(context.Background(), &pb.Snowflake_KeyCount{Name: test_key, Count: 10000})
+	if err != nil {
+		t.Fatalf("could not get next values: %v", err)
+	}
+	if r.End-r.Start+1 != 10000 {
+		t.Fatalf("unexpected range %v", r)
+	}
+	t.Log(r.Start, r.End)
+}
+
+func TestSnowflakeDeadline(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// an expired request is not served
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	if _, err := c.Next(ctx, &pb.Snowflake_Key{Name: test_key}); grpc.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSnowflakeAdmin(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+	ctx := context.Background()
+	name := "test_admin_key"
+
+	// create, read, compare-and-set, list and delete a sequence
+	c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	if _, err := c.Create(ctx, &pb.Snowflake_KeyValue{Name: name, Value: 100}); err != nil {
+		t.Fatalf("could not create: %v", err)
+	}
+	if _, err := c.Create(ctx, &pb.Snowflake_KeyValue{Name: name, Value: 100}); grpc.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expect AlreadyExists, got %v", err)
+	}
+	if r, err := c.Get(ctx, &pb.Snowflake_Key{Name: name}); err != nil || r.Value != 100 {
+		t.Fatalf("could not get: %v %v", r, err)
+	}
+	if _, err := c.Set(ctx, &pb.Snowflake_CompareAndSet{Name: name, Value: 0, PrevValue: 99}); grpc.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expect FailedPrecondition, got %v", err)
+	}
+	if _, err := c.Set(ctx, &pb.Snowflake_CompareAndSet{Name: name, Value: 0, PrevValue: 100}); err != nil {
+		t.Fatalf("could not set: %v", err)
+	}
+
+	r, err := c.List(ctx, &pb.Snowflake_NullRequest{})
+	if err != nil {
+		t.Fatalf("could not list: %v", err)
+	}
+	found := false
+	for _, kv := range r.Keys {
+		if kv.Name == name && kv.Value == 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("%v not listed", name)
+	}
+
+	if _, err := c.Delete(ctx, &pb.Snowflake_Key{Name: name}); err != nil {
+		t.Fatalf("could not delete: %v", err)
+	}
+	if _, err := c.Get(ctx, &pb.Snowflake_Key{Name: name}); grpc.Code(err) != codes.NotFound {
+		t.Fatalf("expect NotFound, got %v", err)
+	}
+
+	// details tell clients not to retry
+	var trailer metadata.MD
+	if _, err := c.Next(ctx, &pb.Snowflake_Key{Name: name}, grpc.Trailer(&trailer)); grpc.Code(err) != codes.NotFound {
+		t.Fatalf("expect NotFound, got %v", err)
+	}
+	if d, ok := errdetail.FromMD(trailer); !ok || d.Reason != errdetail.KEY_NOT_FOUND || d.Retryable {
+		t.Fatalf("unexpected error detail %v of %v", d, trailer)
+	}
+}
+
+func TestSnowflakeSequenceOptions(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+	ctx := context.Background()
+	name := "test_options_key"
+
+	// descending sequence 3, 2, 1 then exhausted
+	c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	opts := &pb.Snowflake_Options{Increment: -1, MinValue: 1, MaxValue: 3}
+	if _, err := c.Create(ctx, &pb.Snowflake_KeyValue{Name: name, Value: 4, Options: opts}); err != nil {
+		t.Fatalf("could not create: %v", err)
+	}
+	for _, expect := range []int64{3, 2, 1} {
+		r, err := c.Next(ctx, &pb.Snowflake_Key{Name: name})
+		if err != nil || r.Value != expect {
+			t.Fatalf("expect %v, got %v %v", expect, r, err)
+		}
+	}
+	if _, err := c.Next(ctx, &pb.Snowflake_Key{Name: name}); grpc.Code(err) != codes.OutOfRange {
+		t.Fatalf("expect OutOfRange, got %v", err)
+	}
+
+	// cycling sequence restarts from max_value
+	c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	opts.Cycle = true
+	if _, err := c.Create(ctx, &pb.Snowflake_KeyValue{Name: name, Value: 1, Options: opts}); err != nil {
+		t.Fatalf("could not create: %v", err)
+	}
+	if r, err := c.Next(ctx, &pb.Snowflake_Key{Name: name}); err != nil || r.Value != 3 {
+		t.Fatalf("expect 3, got %v %v", r, err)
+	}
+	c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+}
+
+func BenchmarkSnowflakeParallelKeys(b *testing.B) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		b.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+
+	// goroutines spread over independent keys
+	const keys = 64
+	for i := 0; i < keys; i++ {
+		_, err := c.Create(context.Background(), &pb.Snowflake_KeyValue{Name: fmt.Sprintf("%v_%v", test_key, i)})
+		if err != nil && grpc.Code(err) != codes.AlreadyExists {
+			b.Fatalf("could not create key: %v", err)
+		}
+	}
+
+	var n int64
+	b.SetParallelism(keys)
+	b.RunParallel(func(p *testing.PB) {
+		key := &pb.Snowflake_Key{Name: fmt.Sprintf("%v_%v", test_key, atomic.AddInt64(&n, 1)%keys)}
+		for p.Next() {
+			if _, err := c.Next(context.Background(), key); err != nil {
+				b.Fatalf("could not get next value: %v", err)
+			}
+		}
+	})
+}
+
+func TestSnowflakeAutoCreate(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+	ctx := context.Background()
+	name := "test_auto_key"
+
+	// created on first use, concurrent callers share one sequence
+	c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	defer c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	values := make(chan int64, 8)
+	for i := 0; i < cap(values); i++ {
+		go func() {
+			r, err := c.Next(ctx, &pb.Snowflake_Key{Name: name, AutoCreate: true})
+			if err != nil {
+				t.Error(err)
+				values <- 0
+				return
+			}
+			values <- r.Value
+		}()
+	}
+	seen := make(map[int64]bool)
+	var max int64
+	for i := 0; i < cap(values); i++ {
+		v := <-values
+		if seen[v] {
+			t.Fatalf("duplicated value %v", v)
+		}
+		seen[v] = true
+		if v > max {
+			max = v
+		}
+	}
+	if r, err := c.Get(ctx, &pb.Snowflake_Key{Name: name}); err != nil || r.Value != max {
+		t.Fatalf("could not get: %v %v", r, err)
+	}
+}
+
+func TestSnowflakeNextFormatted(t *testing.T) {
+	// Set up a connection to the server.
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("did not connect: %v", err)
+	}
+	defer conn.Close()
+	c := pb.NewSnowflakeServiceClient(conn)
+	ctx := context.Background()
+	name := "test_daily_key"
+
+	c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	defer c.Delete(ctx, &pb.Snowflake_Key{Name: name})
+	_, err = c.Create(ctx, &pb.Snowflake_KeyValue{
+		Name:    name,
+		Value:   122,
+		Options: &pb.Snowflake_Options{ResetPeriod: "day"},
+		Format:  &pb.Snowflake_Format{Prefix: "INV-", CheckDigit: "damm"},
+	})
+	if err != nil {
+		t.Fatalf("could not create: %v", err)
+	}
+	r, err := c.NextFormatted(ctx, &pb.Snowflake_Key{Name: name})
+	if err != nil {
+		t.Fatalf("could not get next value: %v", err)
+	}
+	if r.Value != 123 || !strings.HasPrefix(r.Text, "INV-"+r.Bucket+"-000123") || len(r.Bucket) != len("20060102") {
+		t.Fatal(r)
+	}
+	t.Log(r.Text)
+
+	if v, err := c.Validate(ctx, &pb.Snowflake_Validation{Name: name, Text: r.Text}); err != nil || !v.Valid {
+		t.Fatalf("could not validate: %v %v", v, err)
+	}
+}
+
+// new_test_server returns a server on the memory store with the default flags,
+// without machine id and uuid generator.
+func new_test_server(t *testing.T) *server {
+	return &server{
+		pkroot:        "/seqs",
+		uuidkey:       "/seqs/snowflake-uuid",
+		store:         store.NewMemory(),
+		slots:         make(chan struct{}, CONCURRENT),
+		segments:      make(map[string]*segment),
+		segment_steps: make(map[string]int64),
+		auto_start:    1,
+		now:           time.Now,
+	}
+}
+
+// conflict_store fails every CompareAndSwap, as if other instances always won
+type conflict_store struct {
+	store.Store
+}
+
+func (conflict_store) CompareAndSwap(context.Context, string, []byte, int64) error {
+	return store.ErrConflict
+}
+
+func TestIncrConflicts(t *testing.T) {
+	s := new_test_server(t)
+	s.store = conflict_store{s.store}
+	if err := s.store.Put(context.Background(), "/seqs/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// retries are capped
+	if _, _, _, err := s.incr(context.Background(), "a", 1, false); grpc.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted, got %v", err)
+	}
+
+	// backoff stops with the request
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := s.incr(ctx, "a", 1, false); grpc.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, _, _, err := s.incr(ctx, "a", 1, false); grpc.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+}
+
+func TestIncrQueue(t *testing.T) {
+	s := new_test_server(t)
+	s.slots = make(chan struct{}, 1)
+	if err := s.store.Put(context.Background(), "/seqs/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+
+	// waits for a slot while the queue has room
+	s.slots <- struct{}{}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.release()
+	}()
+	if _, _, _, err := s.incr(context.Background(), "a", 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// rejected once the queue is full
+	s.slots <- struct{}{}
+	s.waiting = QUEUE_SIZE
+	if _, _, _, err := s.incr(context.Background(), "a", 1, false); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestIncrReserved(t *testing.T) {
+	s := new_test_server(t)
+	ctx := context.Background()
+	s.store.Put(ctx, "/seqs/snowflake-uuid/high-water/0", []byte("0"))
+
+	// machine ids, high-water and hidden keys can't be advanced
+	for _, name := range []string{
+		"snowflake-uuid",
+		"snowflake-uuid/high-water/0",
+		"_options/a",
+		"_buckets/inv/20161018",
+		"_templates/orders/*",
+		"_formats/a",
+	} {
+		if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: name}); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument, got %v", name, err)
+		}
+		if _, err := s.NextN(ctx, &pb.Snowflake_KeyCount{Name: name, Count: 1e12}); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument, got %v", name, err)
+		}
+		if _, err := s.NextFormatted(ctx, &pb.Snowflake_Key{Name: name}); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument, got %v", name, err)
+		}
+	}
+	s.segment_default = 10
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "_options/a"}); grpc.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if kv, err := s.store.Get(ctx, "/seqs/snowflake-uuid/high-water/0"); err != nil || string(kv.Value) != "0" {
+		t.Fatal(kv, err)
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	const min, max = math.MinInt64, math.MaxInt64
+	for _, c := range []struct {
+		in       pb.Snowflake_Options
+		min, max int64
+	}{
+		{pb.Snowflake_Options{}, min, max},
+		{pb.Snowflake_Options{MinValue: 1}, 1, max},
+		{pb.Snowflake_Options{MaxValue: -1, Increment: -1}, min, -1},
+		{pb.Snowflake_Options{MaxValue: 99, Cycle: true}, min, 99},
+		{pb.Snowflake_Options{MaxValue: 99, HasMinValue: true, Cycle: true}, 0, 99},
+		{pb.Snowflake_Options{MinValue: -99}, -99, max},
+		{pb.Snowflake_Options{HasMaxValue: true, Increment: -1}, min, 0},
+		{pb.Snowflake_Options{MinValue: min, MaxValue: max, Increment: max}, min, max},
+		{pb.Snowflake_Options{MinValue: 1, MaxValue: 3, Increment: -2}, 1, 3},
+	} {
+		o, err := new_options(&c.in)
+		if err != nil || o.MinValue != c.min || o.MaxValue != c.max {
+			t.Fatal(c.in, o, err)
+		}
+	}
+
+	for _, in := range []pb.Snowflake_Options{
+		{MinValue: 5, MaxValue: 5},
+		{MinValue: 1, MaxValue: 3, Increment: 3},
+		{MinValue: 1, MaxValue: 3, Increment: -3},
+		{Increment: min},
+		{HasMinValue: true, HasMaxValue: true},
+	} {
+		if o, err := new_options(&in); err == nil {
+			t.Fatal(in, o)
+		}
+	}
+
+	// the initial value is within the bounds, or one increment before them
+	s := new_test_server(t)
+	ctx := context.Background()
+	for _, c := range []struct {
+		value int64
+		in    pb.Snowflake_Options
+		ok    bool
+	}{
+		{0, pb.Snowflake_Options{MinValue: 1}, true},
+		{5, pb.Snowflake_Options{MinValue: 1, MaxValue: 9}, true},
+		{10, pb.Snowflake_Options{MinValue: 1, MaxValue: 9, Increment: -1}, true},
+		{-1, pb.Snowflake_Options{MinValue: 1}, false},
+		{10, pb.Snowflake_Options{MinValue: 1, MaxValue: 9}, false},
+		{max, pb.Snowflake_Options{MaxValue: -1, Increment: -1}, false},
+	} {
+		_, err := s.Create(ctx, &pb.Snowflake_KeyValue{Name: fmt.Sprint("range", c.value, c.in.Increment), Value: c.value, Options: &c.in})
+		if c.ok && err != nil || !c.ok && grpc.Code(err) != codes.InvalidArgument {
+			t.Fatal(c.value, c.in, err)
+		}
+	}
+}
+
+// slow_store delays CompareAndSwap, keeping prefetches in flight while blocks drain
+type slow_store struct {
+	store.Store
+}
+
+func (s slow_store) CompareAndSwap(ctx context.Context, key string, value []byte, rev int64) error {
+	time.Sleep(time.Millisecond)
+	return s.Store.CompareAndSwap(ctx, key, value, rev)
+}
+
+func TestSegment(t *testing.T) {
+	s := new_test_server(t)
+	s.segment_default = 10
+	ctx := context.Background()
+	value := func(name string) int64 {
+		r, err := s.Get(ctx, &pb.Snowflake_Key{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Value
+	}
+
+	// one block reserved, the next one prefetched once 80% is used
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "seg", Options: &pb.Snowflake_Options{Increment: 2}})
+	for i := int64(1); i <= 25; i++ {
+		if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"}); err != nil || r.Value != 2*i {
+			t.Fatal(i, r, err)
+		}
+		switch i {
+		case 7:
+			if v := value("seg"); v != 20 {
+				t.Fatalf("prefetched before 80%%: %v", v)
+			}
+		case 8:
+			for deadline := time.Now().Add(time.Second); value("seg") != 40; {
+				if time.Now().After(deadline) {
+					t.Fatal("not prefetched at 80%")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+
+	// values left within the bounds are served when a block no longer fits
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "bounded", Options: &pb.Snowflake_Options{MinValue: 1, MaxValue: 25}})
+	for i := int64(1); i <= 25; i++ {
+		if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "bounded"}); err != nil || r.Value != i {
+			t.Fatal(i, r, err)
+		}
+	}
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "bounded"}); grpc.Code(err) != codes.OutOfRange {
+		t.Fatalf("expected OutOfRange, got %v", err)
+	}
+}
+
+// failing_cas_store fails CompareAndSwap while failing is set, counting the attempts
+type failing_cas_store struct {
+	store.Store
+	failing  int32
+	attempts int32
+}
+
+func (s *failing_cas_store) CompareAndSwap(ctx context.Context, key string, value []byte, rev int64) error {
+	if atomic.LoadInt32(&s.failing) == 1 {
+		atomic.AddInt32(&s.attempts, 1)
+		return errors.New("store unreachable")
+	}
+	return s.Store.CompareAndSwap(ctx, key, value, rev)
+}
+
+func TestSegmentBackoff(t *testing.T) {
+	s := new_test_server(t)
+	fs := &failing_cas_store{Store: s.store}
+	s.store = fs
+	s.segment_default = 10
+	ctx := context.Background()
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "seg"})
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed prefetch is not retried on every call within the backoff
+	atomic.StoreInt32(&fs.failing, 1)
+	for i := int64(2); i <= 8; i++ {
+		if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"}); err != nil || r.Value != i {
+			t.Fatal(i, r, err)
+		}
+	}
+	s.muSegments.Lock()
+	seg := s.segments["seg"]
+	s.muSegments.Unlock()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		seg.mu.Lock()
+		loading := seg.loading
+		seg.mu.Unlock()
+		if !loading && atomic.LoadInt32(&fs.attempts) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("prefetch not attempted")
+		}
+	}
+	for i := int64(9); i <= 10; i++ {
+		if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"}); err != nil || r.Value != i {
+			t.Fatal(i, r, err)
+		}
+	}
+	if n := atomic.LoadInt32(&fs.attempts); n != 1 {
+		t.Fatalf("expected 1 prefetch attempt, got %v", n)
+	}
+
+	// the error is returned once the block runs out
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"}); err == nil {
+		t.Fatal("expected the store error")
+	}
+	atomic.StoreInt32(&fs.failing, 0)
+	if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"}); err != nil || r.Value != 11 {
+		t.Fatal(r, err)
+	}
+}
+
+func TestSegmentConcurrent(t *testing.T) {
+	const workers, count = 8, 500
+	s := new_test_server(t)
+	s.store = slow_store{s.store}
+	s.segment_default = 10
+	ctx := context.Background()
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "seg"})
+
+	// no gaps and no duplicates across blocks
+	values := make(chan int64, workers*count)
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < count; j++ {
+				r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "seg"})
+				if err != nil {
+					errs <- err
+					return
+				}
+				values <- r.Value
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(values)
+
+	seen := make([]bool, workers*count+1)
+	for v := range values {
+		if v < 1 || v > workers*count || seen[v] {
+			t.Fatalf("unexpected value %v", v)
+		}
+		seen[v] = true
+	}
+	for v := 1; v <= workers*count; v++ {
+		if !seen[v] {
+			t.Fatalf("missing value %v", v)
+		}
+	}
+}
+
+func TestAutoCreate(t *testing.T) {
+	s := new_test_server(t)
+	for k, v := range map[string]string{
+		"/seqs/_templates/orders/*":     `{"start":100000,"increment":1}`,
+		"/seqs/_templates/orders/vip-*": `{"start":900,"increment":-1,"min_value":1,"max_value":900}`,
+	} {
+		if err := s.store.Put(context.Background(), k, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	// missing sequences are not created by default
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "users"}); grpc.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	// the most specific template wins, defaults without any
+	for name, want := range map[string]int64{"orders/2017": 100000, "orders/vip-a": 900, "users": 1} {
+		r, err := s.Next(ctx, &pb.Snowflake_Key{Name: name, AutoCreate: true})
+		if err != nil || r.Value != want {
+			t.Fatal(name, r, err)
+		}
+	}
+	if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "orders/vip-a"}); err != nil || r.Value != 899 {
+		t.Fatal(r, err)
+	}
+
+	// with --auto-create
+	s.auto_create = true
+	if r, err := s.NextN(ctx, &pb.Snowflake_KeyCount{Name: "orders/2018", Count: 10}); err != nil || r.Start != 100000 || r.End != 100009 {
+		t.Fatal(r, err)
+	}
+
+	// hidden and reserved names are never created
+	for _, name := range []string{"_templates/x", "snowflake-uuid/1", "a/"} {
+		if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: name}); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument, got %v", name, err)
+		}
+	}
+
+	// a broken template is reported
+	s.store.Put(ctx, "/seqs/_templates/broken", []byte("{"))
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "broken"}); grpc.Code(err) != codes.DataLoss {
+		t.Fatalf("expected DataLoss, got %v", err)
+	}
+}
+
+func TestResetPeriod(t *testing.T) {
+	s := new_test_server(t)
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, &pb.Snowflake_KeyValue{
+		Name:    "invoice",
+		Options: &pb.Snowflake_Options{ResetPeriod: "day", Timezone: "Asia/Shanghai"},
+		Format:  &pb.Snowflake_Format{Template: "INV{bucket}-{seq:4}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// periods follow the timezone of the sequence
+	for _, c := range []struct {
+		now  string
+		text string
+	}{
+		{"2016-10-18T15:00:00Z", "INV20161018-0001"},
+		{"2016-10-18T15:59:59Z", "INV20161018-0002"},
+		{"2016-10-18T16:00:00Z", "INV20161019-0001"},
+	} {
+		tm, _ := time.Parse(time.RFC3339, c.now)
+		s.now = func() time.Time { return tm }
+		r, err := s.NextFormatted(ctx, &pb.Snowflake_Key{Name: "invoice"})
+		if err != nil || r.Text != c.text {
+			t.Fatal(c.now, r, err)
+		}
+	}
+
+	// never served from segments
+	s.segment_default = 100
+	if r, err := s.Next(ctx, &pb.Snowflake_Key{Name: "invoice"}); err != nil || r.Value != 2 {
+		t.Fatal(r, err)
+	}
+	s.segment_default = 0
+
+	// Get reports the counter of the current period, the value of the sequence before it starts
+	if r, err := s.Get(ctx, &pb.Snowflake_Key{Name: "invoice"}); err != nil || r.Value != 2 {
+		t.Fatal(r, err)
+	}
+	tm, _ := time.Parse(time.RFC3339, "2016-10-19T16:00:00Z")
+	s.now = func() time.Time { return tm }
+	if r, err := s.Get(ctx, &pb.Snowflake_Key{Name: "invoice"}); err != nil || r.Value != 0 {
+		t.Fatal(r, err)
+	}
+
+	// nested sequences named like periods keep their own counters, buckets are not listed
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "invoice/20161019", Value: 7})
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "invoice/eu", Options: &pb.Snowflake_Options{ResetPeriod: "day"}})
+	if _, err := s.Next(ctx, &pb.Snowflake_Key{Name: "invoice/eu"}); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.List(ctx, &pb.Snowflake_NullRequest{}); err != nil || len(r.Keys) != 3 {
+		t.Fatal(r, err)
+	}
+
+	// plain sequences and the default formats
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "plain", Value: 41})
+	if r, err := s.NextFormatted(ctx, &pb.Snowflake_Key{Name: "plain"}); err != nil || r.Text != "42" || r.Bucket != "" {
+		t.Fatal(r, err)
+	}
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "monthly", Options: &pb.Snowflake_Options{ResetPeriod: "month"}})
+	if r, err := s.NextFormatted(ctx, &pb.Snowflake_Key{Name: "monthly"}); err != nil || r.Text != "201610-000001" {
+		t.Fatal(r, err)
+	}
+
+	// the counters of periods are deleted with the sequence
+	if _, err := s.Delete(ctx, &pb.Snowflake_Key{Name: "invoice"}); err != nil {
+		t.Fatal(err)
+	}
+	if kvs, err := s.store.List(ctx, s.bucket_key("invoice", "")); err != nil || len(kvs) != 1 || !strings.HasPrefix(kvs[0].Key, s.bucket_key("invoice/eu", "")) {
+		t.Fatal(kvs, err)
+	}
+	if r, err := s.Get(ctx, &pb.Snowflake_Key{Name: "invoice/20161019"}); err != nil || r.Value != 7 {
+		t.Fatal(r, err)
+	}
+
+	// malformed options are reported, the sequence is kept
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "broken"})
+	s.store.Put(ctx, s.options_key("broken"), []byte("{"))
+	if _, err := s.Delete(ctx, &pb.Snowflake_Key{Name: "broken"}); grpc.Code(err) != codes.DataLoss {
+		t.Fatalf("expected DataLoss, got %v", err)
+	}
+	if _, err := s.store.Get(ctx, s.pkroot+"/broken"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, in := range []*pb.Snowflake_KeyValue{
+		{Name: "bad", Options: &pb.Snowflake_Options{ResetPeriod: "week"}},
+		{Name: "bad", Options: &pb.Snowflake_Options{Timezone: "UTC"}},
+		{Name: "bad", Options: &pb.Snowflake_Options{ResetPeriod: "day", Timezone: "Mars/Olympus"}},
+		{Name: "bad", Format: &pb.Snowflake_Format{Template: "{bucket}"}},
+		{Name: "bad", Format: &pb.Snowflake_Format{Template: "{seq:20}"}},
+		{Name: "bad", Format: &pb.Snowflake_Format{Template: "{seq"}},
+	} {
+		if _, err := s.Create(ctx, in); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument, got %v", in, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s := new_test_server(t)
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, &pb.Snowflake_KeyValue{
+		Name:   "invoice",
+		Format: &pb.Snowflake_Format{Prefix: "INV-", Padding: 6, CheckDigit: "luhn"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.NextFormatted(ctx, &pb.Snowflake_Key{Name: "invoice"})
+	if err != nil || r.Value != 1 || r.Text != "INV-0000018" {
+		t.Fatal(r, err)
+	}
+
+	// typos, transpositions and foreign prefixes are caught
+	for text, want := range map[string]bool{
+		"INV-0000018":   true,
+		"INV-0000019":   false,
+		"INV-0000081":   false,
+		"ORD-0000018":   false,
+		"INV-":          false,
+		"INV-00-00-018": false,
+		"INV-0x0000018": false,
+		"INV-00000018":  false,
+	} {
+		v, err := s.Validate(ctx, &pb.Snowflake_Validation{Name: "invoice", Text: text})
+		if err != nil || v.Valid != want {
+			t.Fatal(text, v, err)
+		}
+	}
+	if v, err := s.Validate(ctx, &pb.Snowflake_Validation{Text: "5724", CheckDigit: "damm"}); err != nil || !v.Valid {
+		t.Fatal(v, err)
+	}
+	if v, err := s.Validate(ctx, &pb.Snowflake_Validation{Text: "57-24", CheckDigit: "damm"}); err != nil || v.Valid {
+		t.Fatal(v, err)
+	}
+
+	// buckets have the width of their period
+	tm, _ := time.Parse(time.RFC3339, "2016-10-18T00:00:00Z")
+	s.now = func() time.Time { return tm }
+	s.Create(ctx, &pb.Snowflake_KeyValue{
+		Name:    "receipt",
+		Options: &pb.Snowflake_Options{ResetPeriod: "day"},
+		Format:  &pb.Snowflake_Format{Template: "R{bucket}/{seq:3}", CheckDigit: "damm"},
+	})
+	r, err = s.NextFormatted(ctx, &pb.Snowflake_Key{Name: "receipt"})
+	if err != nil || !strings.HasPrefix(r.Text, "R20161018/001") {
+		t.Fatal(r, err)
+	}
+	for text, want := range map[string]bool{
+		r.Text: true,
+		r.Text[:8] + "/" + r.Text[8:9] + r.Text[10:]: false,
+	} {
+		v, err := s.Validate(ctx, &pb.Snowflake_Validation{Name: "receipt", Text: text})
+		if err != nil || v.Valid != want {
+			t.Fatal(text, v, err)
+		}
+	}
+
+	s.Create(ctx, &pb.Snowflake_KeyValue{Name: "plain"})
+	if _, err := s.Validate(ctx, &pb.Snowflake_Validation{Name: "plain", Text: "1"}); grpc.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+	if _, err := s.Validate(ctx, &pb.Snowflake_Validation{Text: "1", CheckDigit: "crc"}); grpc.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	for _, f := range []*pb.Snowflake_Format{{CheckDigit: "crc"}, {Padding: 20}, {Padding: -1}} {
+		if _, err := s.Create(ctx, &pb.Snowflake_KeyValue{Name: "bad", Format: f}); grpc.Code(err) != codes.InvalidArgument {
+			t.Fatalf("%v: expected InvalidArgument, got %v", f, err)
+		}
+	}
+}
+
+func TestMachineLease(t *testing.T) {
+	s := new_test_server(t)
+	s.layout = uuid.Snowflake
+	id, err := s.claim_machine_id(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.gen, err = generator.New(generator.Config{MachineID: generator.StaticMachineID(id)}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := s.GetUUID(ctx, &pb.Snowflake_NullRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// no uuids once the lease may have expired on the store
+	s.renewed = time.Now().Add(-MACHINE_ID_TTL).UnixNano()
+	if _, err := s.GetUUID(ctx, &pb.Snowflake_NullRequest{}); grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	if _, err := s.GetUUIDs(ctx, &pb.Snowflake_UUIDRequest{Count: 1}); grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+
+	// until the next successful heartbeat
+	if err := s.renew_machine_id(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUUID(ctx, &pb.Snowflake_NullRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expired_lease has expired locally while its keys may still live on the store
+type expired_lease struct {
+	store.Lease
+}
+
+func (expired_lease) KeepAlive(context.Context) error {
+	return store.ErrLeaseExpired
+}
+
+func TestMachineReclaim(t *testing.T) {
+	s := new_test_server(t)
+	s.layout = uuid.Snowflake
+	id, err := s.claim_machine_id(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key of the expired lease still holds this process, retried on the next heartbeat
+	expired := expired_lease{s.lease}
+	s.lease = expired
+	if err := s.renew_machine_id(id); err == nil {
+		t.Fatal("reclaimed a key still held by the expired lease")
+	}
+	if s.lease != store.Lease(expired) {
+		t.Fatal("lease replaced without the machine id")
+	}
+
+	// taken back once the key is gone
+	if _, err := s.store.Delete(context.Background(), s.machine_key(id)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.renew_machine_id(id); err != nil {
+		t.Fatal(err)
+	}
+	if kv, err := s.store.Get(context.Background(), s.machine_key(id)); err != nil || string(kv.Value) != machine_owner() {
+		t.Fatal(kv, err)
+	}
+}
+
+// put_failing_store fails every Put, as if the store were unreachable
+type put_failing_store struct {
+	store.Store
+}
+
+func (put_failing_store) Put(context.Context, string, []byte) error {
+	return errors.New("store unreachable")
+}
+
+func TestHighWater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snowflake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := new_test_server(t)
+	s.statefile = filepath.Join(dir, "state")
+	ctx := context.Background()
+
+	if hw, err := s.load_high_water(1); err != nil || hw.UnixNano() != 0 {
+		t.Fatal(hw, err)
+	}
+
+	// persisted ahead of the clock, into the store and the state file
+	if err := s.save_high_water(1); err != nil {
+		t.Fatal(err)
+	}
+	hw, err := s.load_high_water(1)
+	if d := hw.Sub(time.Now()); err != nil || d <= HW_AHEAD-time.Second || d > HW_AHEAD {
+		t.Fatal(hw, err)
+	}
+	if b, err := ioutil.ReadFile(s.statefile); err != nil || string(b) != fmt.Sprint(hw.UnixNano()/int64(time.Millisecond)) {
+		t.Fatal(string(b), err)
+	}
+
+	// the greater one wins
+	ahead := time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond)
+	ioutil.WriteFile(s.statefile, []byte(fmt.Sprint(ahead)), 0644)
+	if hw, err := s.load_high_water(1); err != nil || hw.UnixNano()/int64(time.Millisecond) != ahead {
+		t.Fatal(hw, err)
+	}
+	ioutil.WriteFile(s.statefile, []byte("x"), 0644)
+	if _, err := s.load_high_water(1); err == nil {
+		t.Fatal("malformed state file accepted")
+	}
+	os.Remove(s.statefile)
+	s.store.Put(ctx, s.high_water_key(1), []byte("x"))
+	if _, err := s.load_high_water(1); err == nil {
+		t.Fatal("malformed high-water accepted")
+	}
+
+	// a rewound clock waits for the high-water, or refuses to start
+	s.store.Put(ctx, s.high_water_key(1), []byte(fmt.Sprint(time.Now().Add(200*time.Millisecond).UnixNano()/int64(time.Millisecond))))
+	start := time.Now()
+	if err := s.wait_high_water(1); err != nil || time.Since(start) < 100*time.Millisecond {
+		t.Fatal(time.Since(start), err)
+	}
+	s.store.Put(ctx, s.high_water_key(1), []byte(fmt.Sprint(ahead)))
+	if err := s.wait_high_water(1); err == nil {
+		t.Fatal("waited for a high-water beyond HW_MAX_WAIT")
+	}
+}
+
+func TestHighWaterLimit(t *testing.T) {
+	var clock atomic.Value
+	clock.Store(time.Now())
+	s := new_test_server(t)
+	s.renewed = time.Now().UnixNano()
+	var err error
+	s.gen, err = generator.New(generator.Config{Clock: generator.ClockFunc(func() time.Time { return clock.Load().(time.Time) })})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.save_high_water(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUUID(ctx, &pb.Snowflake_NullRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the store went away, the clock passes the last persisted high-water
+	s.store = put_failing_store{s.store}
+	if err := s.save_high_water(1); err == nil {
+		t.Fatal("save on a failing store")
+	}
+	clock.Store(time.Now().Add(HW_AHEAD + time.Second))
+	if _, err := s.GetUUID(ctx, &pb.Snowflake_NullRequest{}); grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+
+	// the store is back, and the clock of the generator follows the wall clock again
+	s.store = s.store.(put_failing_store).Store
+	clock.Store(time.Now())
+	if err := s.save_high_water(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUUID(ctx, &pb.Snowflake_NullRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// v2_keys serves directory trees of the etcd v2 keys API from memory
+type v2_keys struct {
+	etcd.KeysAPI
+	dirs map[string]*etcd.Node
+}
+
+func (k v2_keys) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	if node, ok := k.dirs[key]; ok {
+		return &etcd.Response{Node: node}, nil
+	}
+	return nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	expiration := time.Now().Add(MACHINE_ID_TTL)
+	keys := v2_keys{dirs: map[string]*etcd.Node{
+		"/seqs": {Key: "/seqs", Dir: true, Nodes: etcd.Nodes{
+			{Key: "/seqs/users", Value: "5"},
+			{Key: "/seqs/orders", Dir: true, Nodes: etcd.Nodes{{Key: "/seqs/orders/2017", Value: "7"}}},
+			{Key: "/seqs/_options", Dir: true, Nodes: etcd.Nodes{{Key: "/seqs/_options/users", Value: `{"increment":2}`}}},
+			{Key: "/seqs/snowflake-uuid", Dir: true, Nodes: etcd.Nodes{
+				{Key: "/seqs/snowflake-uuid/0", Value: "host:1", Expiration: &expiration},
+				{Key: "/seqs/snowflake-uuid/high-water", Dir: true, Nodes: etcd.Nodes{{Key: "/seqs/snowflake-uuid/high-water/0", Value: "1500000000000"}}},
+			}},
+		}},
+		"/seqs/snowflake-uuid/high-water": {Key: "/seqs/snowflake-uuid/high-water", Dir: true, Nodes: etcd.Nodes{{Key: "/seqs/snowflake-uuid/high-water/0", Value: "1500000000000"}}},
+		"/uuid/high-water":                {Key: "/uuid/high-water", Dir: true, Nodes: etcd.Nodes{{Key: "/uuid/high-water/1", Value: "1600000000000"}}},
+	}}
+	dst := store.NewMemory()
+	dst.Put(ctx, "/seqs/orders/2017", []byte("9"))
+
+	// machine ids are claimed again, existing keys are kept, high-water keys under pk-root are copied once
+	copied, skipped, err := migrate_keys(ctx, keys, dst, "/seqs", "/seqs/snowflake-uuid")
+	if err != nil || copied != 3 || skipped != 1 {
+		t.Fatal(copied, skipped, err)
+	}
+	for k, v := range map[string]string{
+		"/seqs/users":                       "5",
+		"/seqs/orders/2017":                 "9",
+		"/seqs/_options/users":              `{"increment":2}`,
+		"/seqs/snowflake-uuid/high-water/0": "1500000000000",
+	} {
+		if kv, err := dst.Get(ctx, k); err != nil || string(kv.Value) != v {
+			t.Fatal(k, kv, err)
+		}
+	}
+	if _, err := dst.Get(ctx, "/seqs/snowflake-uuid/0"); err != store.ErrNotFound {
+		t.Fatal("machine id migrated:", err)
+	}
+
+	// high-water keys out of pk-root, the rest is skipped on a second run
+	copied, skipped, err = migrate_keys(ctx, keys, dst, "/seqs", "/uuid")
+	if err != nil || copied != 1 || skipped != 4 {
+		t.Fatal(copied, skipped, err)
+	}
+	if kv, err := dst.Get(ctx, "/uuid/high-water/1"); err != nil || string(kv.Value) != "1600000000000" {
+		t.Fatal(kv, err)
+	}
+
+	// nothing to migrate
+	if copied, skipped, err := migrate_keys(ctx, keys, store.NewMemory(), "/none", "/none/snowflake-uuid"); err != nil || copied != 0 || skipped != 0 {
+		t.Fatal(copied, skipped, err)
+	}
+}
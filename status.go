@@ -0,0 +1,80 @@
+package main
+
+import (
+	"snowflake/errdetail"
+	"snowflake/generator"
+	"snowflake/store"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// every error returned to clients carries a grpc code, and a reason in the trailer,
+// see package errdetail.
+
+// status_error returns a grpc error, the reason is sent with the trailer of the request of ctx
+func status_error(ctx context.Context, code codes.Code, reason string, format string, args ...interface{}) error {
+	return detail_error(ctx, code, errdetail.New(reason), format, args...)
+}
+
+func detail_error(ctx context.Context, code codes.Code, d errdetail.Detail, format string, args ...interface{}) error {
+	// fails only without a grpc stream in ctx, eg: in-process calls
+	grpc.SetTrailer(ctx, d.MD())
+	return grpc.Errorf(code, format, args...)
+}
+
+// store_error converts unexpected storage errors,
+// DeadlineExceeded or Canceled if the request is done, Unavailable otherwise.
+func store_error(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return status_error(ctx, codes.DeadlineExceeded, errdetail.DEADLINE_EXCEEDED, "%v", err)
+	case context.Canceled:
+		return status_error(ctx, codes.Canceled, errdetail.CANCELED, "%v", err)
+	}
+	if err == store.ErrMalformed {
+		return status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "%v", err)
+	}
+	return status_error(ctx, codes.Unavailable, errdetail.STORE_UNAVAILABLE, "%v", err)
+}
+
+// conflict_error is returned when concurrent writes to a sequence keep winning
+func conflict_error(ctx context.Context, name string) error {
+	d := errdetail.New(errdetail.TOO_MANY_CONFLICTS)
+	d.RetryAfter = BACKOFF * time.Millisecond
+	return detail_error(ctx, codes.Aborted, d, "sequence %v: too many conflicts, retry later", name)
+}
+
+// uuid_error converts generator errors
+func uuid_error(ctx context.Context, err error) error {
+	switch err {
+	case generator.ErrClockBackward:
+		return status_error(ctx, codes.Unavailable, errdetail.CLOCK_BACKWARD, "%v", err)
+	case generator.ErrBeyondLimit:
+		return status_error(ctx, codes.Unavailable, errdetail.HIGH_WATER_STALE, "high-water not persisted: %v", err)
+	case generator.ErrBeforeEpoch, generator.ErrOverflow:
+		return status_error(ctx, codes.FailedPrecondition, errdetail.CLOCK_UNUSABLE, "%v", err)
+	}
+	return status_error(ctx, codes.Internal, errdetail.INTERNAL, "%v", err)
+}
+
+// machine_error is returned for uuid requests while the machine id may have been taken by others
+func machine_error(ctx context.Context, since time.Duration) error {
+	d := errdetail.New(errdetail.MACHINE_ID_EXPIRED)
+	d.RetryAfter = MACHINE_ID_TTL / 3
+	return detail_error(ctx, codes.Unavailable, d, "machine id lease not renewed for %v", since)
+}
+
+// not_found_error is returned for missing sequences
+func not_found_error(ctx context.Context, name string) error {
+	return status_error(ctx, codes.NotFound, errdetail.KEY_NOT_FOUND, "sequence %v not exists, need to create first", name)
+}
+
+// queue_error is returned when the queue of requests waiting for the store is full
+func queue_error(ctx context.Context) error {
+	d := errdetail.New(errdetail.QUEUE_FULL)
+	d.RetryAfter = BACKOFF * time.Millisecond
+	return detail_error(ctx, codes.ResourceExhausted, d, "too many requests waiting for the store, retry later")
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucket_kvs   = []byte("kvs")
+	bucket_meta  = []byte("meta")
+	key_revision = []byte("revision")
+)
+
+// bolt_store serves from memory and writes through to a bolt database file,
+// keys attached to leases live in memory only, as no lease outlives the process.
+type bolt_store struct {
+	*memory
+	db *bolt.DB
+}
+
+// NewBolt opens or creates a Store in a bolt database file, for single node deployments
+func NewBolt(path string) (Store, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	// load all keys, values are prefixed with 8 bytes of revision
+	m := new_memory()
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucket_meta)
+		if err != nil {
+			return err
+		}
+		if v := meta.Get(key_revision); v != nil {
+			m.revision = int64(binary.BigEndian.Uint64(v))
+		}
+
+		kvs, err := tx.CreateBucketIfNotExists(bucket_kvs)
+		if err != nil {
+			return err
+		}
+		return kvs.ForEach(func(k, v []byte) error {
+			value := make([]byte, len(v)-8)
+			copy(value, v[8:])
+			m.kvs[string(k)] = &entry{value: value, revision: int64(binary.BigEndian.Uint64(v))}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &bolt_store{memory: m, db: db}
+	m.sync = s.sync
+	return s, nil
+}
+
+// sync writes one revision in a bolt transaction
+func (s *bolt_store) sync(revision int64, puts []*KV, dels []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rev := make([]byte, 8)
+		binary.BigEndian.PutUint64(rev, uint64(revision))
+		if err := tx.Bucket(bucket_meta).Put(key_revision, rev); err != nil {
+			return err
+		}
+
+		kvs := tx.Bucket(bucket_kvs)
+		for _, key := range dels {
+			if err := kvs.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		for _, kv := range puts {
+			if err := kvs.Put([]byte(kv.Key), append(rev, kv.Value...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *bolt_store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"golang.org/x/net/context"
+)
+
+// etcd stores keys in etcd v3, revisions are the mod revisions of keys
+type etcd struct {
+	client *clientv3.Client
+}
+
+type etcd_lease struct {
+	client *clientv3.Client
+	id     clientv3.LeaseID
+}
+
+// NewEtcd creates a Store on an etcd v3 client
+func NewEtcd(client *clientv3.Client) Store {
+	return &etcd{client: client}
+}
+
+func (s *etcd) Get(ctx context.Context, key string) (*KV, error) {
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrNotFound
+	}
+	kv := resp.Kvs[0]
+	return &KV{Key: key, Value: kv.Value, Revision: kv.ModRevision}, nil
+}
+
+func (s *etcd) List(ctx context.Context, prefix string) ([]*KV, error) {
+	resp, err := s.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+
+	kvs := make([]*KV, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		kvs = append(kvs, &KV{Key: string(kv.Key), Value: kv.Value, Revision: kv.ModRevision})
+	}
+	return kvs, nil
+}
+
+func (s *etcd) Put(ctx context.Context, key string, value []byte) error {
+	_, err := s.client.Put(ctx, key, string(value))
+	return err
+}
+
+func (s *etcd) Create(ctx context.Context, key string, value []byte, extra ...*KV) error {
+	ops := []clientv3.Op{clientv3.OpPut(key, string(value))}
+	for _, kv := range extra {
+		ops = append(ops, clientv3.OpPut(kv.Key, string(kv.Value)))
+	}
+
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrExists
+	}
+	return nil
+}
+
+func (s *etcd) CompareAndSwap(ctx context.Context, key string, value []byte, revision int64) error {
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		if resp.Responses[0].GetResponseRange().Count == 0 {
+			return ErrNotFound
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *etcd) Delete(ctx context.Context, key string, extra ...string) (*KV, error) {
+	ops := []clientv3.Op{clientv3.OpDelete(key, clientv3.WithPrevKV())}
+	for _, k := range extra {
+		ops = append(ops, clientv3.OpDelete(k))
+	}
+
+	resp, err := s.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), ">", 0)).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		return nil, ErrNotFound
+	}
+
+	kv := &KV{Key: key}
+	if prev := resp.Responses[0].GetResponseDeleteRange().PrevKvs; len(prev) > 0 {
+		kv.Value, kv.Revision = prev[0].Value, prev[0].ModRevision
+	}
+	return kv, nil
+}
+
+func (s *etcd) Grant(ctx context.Context, ttl time.Duration) (Lease, error) {
+	resp, err := s.client.Grant(ctx, int64(ttl/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return &etcd_lease{client: s.client, id: resp.ID}, nil
+}
+
+func (s *etcd) Close() error {
+	return s.client.Close()
+}
+
+func (l *etcd_lease) Claim(ctx context.Context, key string, value []byte) error {
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(value), clientv3.WithLease(l.id))).
+		Commit()
+	if err != nil {
+		if err == rpctypes.ErrLeaseNotFound {
+			return ErrLeaseExpired
+		}
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrExists
+	}
+	return nil
+}
+
+func (l *etcd_lease) KeepAlive(ctx context.Context) error {
+	_, err := l.client.KeepAliveOnce(ctx, l.id)
+	if err == rpctypes.ErrLeaseNotFound {
+		return ErrLeaseExpired
+	}
+	return err
+}
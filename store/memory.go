@@ -0,0 +1,245 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type entry struct {
+	value    []byte
+	revision int64
+	lease    int64 // id of the attached lease, 0 for none
+}
+
+type lease struct {
+	m      *memory
+	id     int64
+	ttl    time.Duration
+	expire time.Time
+}
+
+// memory keeps all keys in a map, every write bumps the global revision,
+// leases expire lazily on the next write, reads skip keys of expired leases.
+// calls never block on I/O, so ctx is ignored.
+type memory struct {
+	mu         sync.Mutex
+	revision   int64
+	kvs        map[string]*entry
+	leases     map[int64]*lease
+	last_lease int64
+	now        func() time.Time // clock of lease expiry
+
+	// sync persists the keys written and removed in one revision before they are applied,
+	// keys attached to leases are never persisted, nil for memory only.
+	sync func(revision int64, puts []*KV, dels []string) error
+}
+
+// NewMemory creates a Store in process memory, for tests and single node deployments
+func NewMemory() Store {
+	return new_memory()
+}
+
+func new_memory() *memory {
+	return &memory{kvs: make(map[string]*entry), leases: make(map[int64]*lease), now: time.Now}
+}
+
+func (m *memory) Get(ctx context.Context, key string) (*KV, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.kvs[key]
+	if !ok || !m.live(e) {
+		return nil, ErrNotFound
+	}
+	return e.kv(key), nil
+}
+
+func (m *memory) List(ctx context.Context, prefix string) ([]*KV, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kvs := []*KV{}
+	for k, e := range m.kvs {
+		if strings.HasPrefix(k, prefix) && m.live(e) {
+			kvs = append(kvs, e.kv(k))
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs, nil
+}
+
+func (m *memory) Put(ctx context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+	return m.commit([]*KV{{Key: key, Value: value}}, 0, nil)
+}
+
+func (m *memory) Create(ctx context.Context, key string, value []byte, extra ...*KV) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	if _, ok := m.kvs[key]; ok {
+		return ErrExists
+	}
+	return m.commit(append([]*KV{{Key: key, Value: value}}, extra...), 0, nil)
+}
+
+func (m *memory) CompareAndSwap(ctx context.Context, key string, value []byte, revision int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	e, ok := m.kvs[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if e.revision != revision {
+		return ErrConflict
+	}
+	return m.commit([]*KV{{Key: key, Value: value}}, 0, nil)
+}
+
+func (m *memory) Delete(ctx context.Context, key string, extra ...string) (*KV, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	e, ok := m.kvs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	kv := e.kv(key)
+	if err := m.commit(nil, 0, append([]string{key}, extra...)); err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
+
+func (m *memory) Grant(ctx context.Context, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.last_lease++
+	l := &lease{m: m, id: m.last_lease, ttl: ttl, expire: m.now().Add(ttl)}
+	m.leases[l.id] = l
+	return l, nil
+}
+
+func (m *memory) Close() error {
+	return nil
+}
+
+// commit applies one revision of writes, puts are attached to lease if it's not 0
+func (m *memory) commit(puts []*KV, lease int64, dels []string) error {
+	revision := m.revision + 1
+	if m.sync != nil {
+		persist := puts
+		if lease != 0 {
+			persist = nil
+		}
+		if err := m.sync(revision, persist, dels); err != nil {
+			return err
+		}
+	}
+
+	m.revision = revision
+	for _, key := range dels {
+		delete(m.kvs, key)
+	}
+	for _, kv := range puts {
+		value := make([]byte, len(kv.Value))
+		copy(value, kv.Value)
+		m.kvs[kv.Key] = &entry{value: value, revision: revision, lease: lease}
+	}
+	return nil
+}
+
+// Incr advances a sequence under the lock, no retries needed
+func (m *memory) Incr(ctx context.Context, key string, n int64, b Bounds) (int64, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	e, ok := m.kvs[key]
+	if !ok {
+		return 0, 0, ErrNotFound
+	}
+	prev, err := strconv.ParseInt(string(e.value), 10, 64)
+	if err != nil {
+		return 0, 0, ErrMalformed
+	}
+	first, last, ok := b.Advance(prev, n)
+	if !ok {
+		return 0, 0, ErrOutOfRange
+	}
+	if err := m.commit([]*KV{{Key: key, Value: []byte(strconv.FormatInt(last, 10))}}, 0, nil); err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+// expire removes expired leases and their keys
+func (m *memory) expire() {
+	now := m.now()
+	for id, l := range m.leases {
+		if now.Before(l.expire) {
+			continue
+		}
+		delete(m.leases, id)
+		for k, e := range m.kvs {
+			if e.lease == id {
+				delete(m.kvs, k)
+			}
+		}
+	}
+}
+
+// live reports whether a key is not attached to an expired lease
+func (m *memory) live(e *entry) bool {
+	if e.lease == 0 {
+		return true
+	}
+	l, ok := m.leases[e.lease]
+	return ok && m.now().Before(l.expire)
+}
+
+func (e *entry) kv(key string) *KV {
+	value := make([]byte, len(e.value))
+	copy(value, e.value)
+	return &KV{Key: key, Value: value, Revision: e.revision}
+}
+
+func (l *lease) Claim(ctx context.Context, key string, value []byte) error {
+	m := l.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	if _, ok := m.leases[l.id]; !ok {
+		return ErrLeaseExpired
+	}
+	if _, ok := m.kvs[key]; ok {
+		return ErrExists
+	}
+	return m.commit([]*KV{{Key: key, Value: value}}, l.id, nil)
+}
+
+func (l *lease) KeepAlive(ctx context.Context) error {
+	m := l.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	if _, ok := m.leases[l.id]; !ok {
+		return ErrLeaseExpired
+	}
+	l.expire = m.now().Add(l.ttl)
+	return nil
+}
@@ -0,0 +1,650 @@
+package store
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/hashicorp/raft"
+	"golang.org/x/net/context"
+)
+
+const (
+	RAFT_TIMEOUT   = 5 * time.Second       // max wait for a leader and for a command to commit
+	RAFT_RETRY     = 50 * time.Millisecond // interval of looking for a leader
+	RAFT_POOL      = 16                    // max idle connections to the leader
+	RAFT_SNAPSHOTS = 2                     // snapshots retained
+	RAFT_DIAL      = 10 * time.Second      // dial timeout of raft connections
+	RAFT_MUX_RAFT  = byte('R')             // first byte of raft connections
+	RAFT_MUX_FWD   = byte('F')             // first byte of forwarding connections
+	RAFT_DEDUP     = 4 * RAFT_TIMEOUT      // how long results are kept for retried commands
+)
+
+var ErrNoLeader = errors.New("no raft leader")
+
+// commands are replicated as json, reads go through the log as well,
+// so they are linearizable and observe leases by the replicated clock.
+// a command forwarded again after a lost reply keeps its id, and is applied once.
+const (
+	op_get       = "get"
+	op_list      = "list"
+	op_put       = "put"
+	op_create    = "create"
+	op_cas       = "cas"
+	op_delete    = "delete"
+	op_incr      = "incr"
+	op_grant     = "grant"
+	op_claim     = "claim"
+	op_keepalive = "keepalive"
+)
+
+type raft_command struct {
+	ID       string        `json:"id,omitempty"`
+	Op       string        `json:"op"`
+	Key      string        `json:"key,omitempty"`
+	Value    []byte        `json:"value,omitempty"`
+	Extra    []*KV         `json:"extra,omitempty"`
+	Keys     []string      `json:"keys,omitempty"`
+	Revision int64         `json:"revision,omitempty"`
+	N        int64         `json:"n,omitempty"`
+	Bounds   Bounds        `json:"bounds"`
+	Lease    int64         `json:"lease,omitempty"`
+	TTL      time.Duration `json:"ttl,omitempty"`
+	Time     int64         `json:"time,omitempty"` // clock of the leader in nanoseconds, drives lease expiry
+}
+
+type raft_result struct {
+	KV        *KV    `json:"kv,omitempty"`
+	KVs       []*KV  `json:"kvs,omitempty"`
+	First     int64  `json:"first,omitempty"`
+	Last      int64  `json:"last,omitempty"`
+	Lease     int64  `json:"lease,omitempty"`
+	Err       string `json:"err,omitempty"`
+	NotLeader bool   `json:"not_leader,omitempty"`
+}
+
+// raft_errors are passed by message between nodes
+var raft_errors = []error{ErrNotFound, ErrExists, ErrConflict, ErrLeaseExpired, ErrOutOfRange, ErrMalformed, context.Canceled, context.DeadlineExceeded}
+
+// raft_store replicates a memory store among a raft group,
+// followers forward all commands to the leader, which applies them through the raft log.
+// leases expire by the replicated clock, which advances with every command.
+type raft_store struct {
+	raft    *raft.Raft
+	fsm     *raft_fsm
+	forward func(ctx context.Context, leader raft.ServerAddress, cmd *raft_command) (*raft_result, error)
+	closers []io.Closer
+}
+
+type raft_lease struct {
+	s  *raft_store
+	id int64
+}
+
+// NewRaft joins a raft group, listening on addr for both raft and forwarded commands,
+// peers are id=addr of all initial members including this node, empty for a single member,
+// the group is bootstrapped with them unless dir already holds raft state.
+func NewRaft(id, addr, dir string, peers []string) (Store, error) {
+	if len(peers) == 0 {
+		peers = []string{id + "=" + addr}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	logs, err := new_raft_log(filepath.Join(dir, "raft.db"))
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := raft.NewFileSnapshotStore(dir, RAFT_SNAPSHOTS, os.Stderr)
+	if err != nil {
+		logs.Close()
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		logs.Close()
+		return nil, err
+	}
+	mux := &raft_mux{ln: ln, conns: make(chan net.Conn), closed: make(chan struct{})}
+	trans := raft.NewNetworkTransport(mux, RAFT_POOL, RAFT_DIAL, os.Stderr)
+
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(id)
+	s, err := new_raft(conf, peers, logs, logs, snaps, trans)
+	if err != nil {
+		trans.Close()
+		logs.Close()
+		return nil, err
+	}
+	fwd := &raft_forwarder{pool: make(map[raft.ServerAddress]chan *raft_conn)}
+	s.forward = fwd.forward
+	s.closers = append(s.closers, trans, logs)
+	go mux.serve(s.serve_forward)
+	return s, nil
+}
+
+// new_raft starts a raft node on given stores and transport
+func new_raft(conf *raft.Config, peers []string, logs raft.LogStore, stable raft.StableStore, snaps raft.SnapshotStore, trans raft.Transport) (*raft_store, error) {
+	fsm := new_raft_fsm()
+	r, err := raft.NewRaft(conf, fsm, logs, stable, snaps, trans)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := raft.HasExistingState(logs, stable, snaps)
+	if err != nil {
+		r.Shutdown()
+		return nil, err
+	}
+	if !exists && len(peers) > 0 {
+		// every member bootstraps with the same configuration
+		var servers []raft.Server
+		for _, p := range peers {
+			i := strings.Index(p, "=")
+			if i <= 0 {
+				r.Shutdown()
+				return nil, fmt.Errorf("malformed raft peer: %v", p)
+			}
+			servers = append(servers, raft.Server{ID: raft.ServerID(p[:i]), Address: raft.ServerAddress(p[i+1:])})
+		}
+		if err := r.BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil && err != raft.ErrCantBootstrap {
+			r.Shutdown()
+			return nil, err
+		}
+	}
+	return &raft_store{raft: r, fsm: fsm}, nil
+}
+
+func (s *raft_store) Get(ctx context.Context, key string) (*KV, error) {
+	res, err := s.execute(ctx, &raft_command{Op: op_get, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return res.KV, nil
+}
+
+func (s *raft_store) List(ctx context.Context, prefix string) ([]*KV, error) {
+	res, err := s.execute(ctx, &raft_command{Op: op_list, Key: prefix})
+	if err != nil {
+		return nil, err
+	}
+	if res.KVs == nil {
+		return []*KV{}, nil
+	}
+	return res.KVs, nil
+}
+
+func (s *raft_store) Put(ctx context.Context, key string, value []byte) error {
+	_, err := s.execute(ctx, &raft_command{Op: op_put, Key: key, Value: value})
+	return err
+}
+
+func (s *raft_store) Create(ctx context.Context, key string, value []byte, extra ...*KV) error {
+	_, err := s.execute(ctx, &raft_command{Op: op_create, Key: key, Value: value, Extra: extra})
+	return err
+}
+
+func (s *raft_store) CompareAndSwap(ctx context.Context, key string, value []byte, revision int64) error {
+	_, err := s.execute(ctx, &raft_command{Op: op_cas, Key: key, Value: value, Revision: revision})
+	return err
+}
+
+func (s *raft_store) Delete(ctx context.Context, key string, extra ...string) (*KV, error) {
+	res, err := s.execute(ctx, &raft_command{Op: op_delete, Key: key, Keys: extra})
+	if err != nil {
+		return nil, err
+	}
+	return res.KV, nil
+}
+
+// Incr advances a sequence with one log entry
+func (s *raft_store) Incr(ctx context.Context, key string, n int64, b Bounds) (int64, int64, error) {
+	res, err := s.execute(ctx, &raft_command{Op: op_incr, Key: key, N: n, Bounds: b})
+	if err != nil {
+		return 0, 0, err
+	}
+	return res.First, res.Last, nil
+}
+
+func (s *raft_store) Grant(ctx context.Context, ttl time.Duration) (Lease, error) {
+	res, err := s.execute(ctx, &raft_command{Op: op_grant, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	return &raft_lease{s: s, id: res.Lease}, nil
+}
+
+func (s *raft_store) Close() error {
+	err := s.raft.Shutdown().Error()
+	for _, c := range s.closers {
+		c.Close()
+	}
+	return err
+}
+
+func (l *raft_lease) Claim(ctx context.Context, key string, value []byte) error {
+	_, err := l.s.execute(ctx, &raft_command{Op: op_claim, Key: key, Value: value, Lease: l.id})
+	return err
+}
+
+func (l *raft_lease) KeepAlive(ctx context.Context) error {
+	_, err := l.s.execute(ctx, &raft_command{Op: op_keepalive, Lease: l.id})
+	return err
+}
+
+// execute runs a command on the leader, waiting for one to be elected
+func (s *raft_store) execute(ctx context.Context, cmd *raft_command) (*raft_result, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cmd.ID = hex.EncodeToString(id)
+
+	deadline := time.Now().Add(RAFT_TIMEOUT)
+	for {
+		var res *raft_result
+		var err error
+		if leader := s.raft.Leader(); leader != "" {
+			if s.raft.State() == raft.Leader {
+				res = s.local(ctx, cmd)
+			} else {
+				res, err = s.forward(ctx, leader, cmd)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Warn("raft forward:", err)
+		} else if res != nil && !res.NotLeader {
+			return res, raft_error(res.Err)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrNoLeader
+		}
+		select {
+		case <-time.After(RAFT_RETRY):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// local runs a command as the leader, the command may still be applied after ctx is done
+func (s *raft_store) local(ctx context.Context, cmd *raft_command) *raft_result {
+	cmd.Time = time.Now().UnixNano()
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return &raft_result{Err: err.Error()}
+	}
+	f := s.raft.Apply(data, RAFT_TIMEOUT)
+	done := make(chan error, 1)
+	go func() { done <- f.Error() }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return &raft_result{Err: ctx.Err().Error()}
+	}
+	if err != nil {
+		// not applied, safe to retry on the new leader
+		if err == raft.ErrNotLeader {
+			return &raft_result{NotLeader: true}
+		}
+		return &raft_result{Err: err.Error()}
+	}
+	return f.Response().(*raft_result)
+}
+
+// serve runs a forwarded command if still the leader
+func (s *raft_store) serve(ctx context.Context, cmd *raft_command) *raft_result {
+	if s.raft.State() != raft.Leader {
+		return &raft_result{NotLeader: true}
+	}
+	return s.local(ctx, cmd)
+}
+
+// serve_forward serves commands forwarded by followers on a connection
+func (s *raft_store) serve_forward(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		cmd := &raft_command{}
+		if err := dec.Decode(cmd); err != nil {
+			return
+		}
+		if err := enc.Encode(s.serve(context.Background(), cmd)); err != nil {
+			return
+		}
+	}
+}
+
+func raft_error(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	for _, err := range raft_errors {
+		if err.Error() == msg {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
+// raft_fsm applies commands to a memory store, the clock of the store is
+// the latest leader clock in the log, so all members expire leases alike.
+type raft_fsm struct {
+	m     *memory
+	clock int64 // accessed under m.mu
+
+	// results of writes within RAFT_DEDUP by the clock, in the order applied,
+	// only accessed by raft, which never applies and snapshots concurrently.
+	applied map[string]*raft_applied
+	recent  []*raft_applied
+}
+
+type raft_applied struct {
+	ID     string       `json:"id"`
+	Time   int64        `json:"time"`
+	Result *raft_result `json:"result"`
+}
+
+func new_raft_fsm() *raft_fsm {
+	f := &raft_fsm{m: new_memory(), applied: make(map[string]*raft_applied)}
+	f.m.now = func() time.Time { return time.Unix(0, f.clock) }
+	return f
+}
+
+func (f *raft_fsm) Apply(l *raft.Log) interface{} {
+	cmd := &raft_command{}
+	if err := json.Unmarshal(l.Data, cmd); err != nil {
+		return &raft_result{Err: err.Error()}
+	}
+
+	f.m.mu.Lock()
+	if cmd.Time > f.clock {
+		f.clock = cmd.Time
+	}
+	clock := f.clock
+	f.m.mu.Unlock()
+
+	// a retried command returns the result of its first apply
+	if a, ok := f.applied[cmd.ID]; ok {
+		return a.Result
+	}
+
+	// commands are applied in memory, no deadline
+	ctx := context.Background()
+	res := &raft_result{}
+	var err error
+	m := f.m
+	switch cmd.Op {
+	case op_get:
+		res.KV, err = m.Get(ctx, cmd.Key)
+	case op_list:
+		res.KVs, err = m.List(ctx, cmd.Key)
+	case op_put:
+		err = m.Put(ctx, cmd.Key, cmd.Value)
+	case op_create:
+		err = m.Create(ctx, cmd.Key, cmd.Value, cmd.Extra...)
+	case op_cas:
+		err = m.CompareAndSwap(ctx, cmd.Key, cmd.Value, cmd.Revision)
+	case op_delete:
+		res.KV, err = m.Delete(ctx, cmd.Key, cmd.Keys...)
+	case op_incr:
+		res.First, res.Last, err = m.Incr(ctx, cmd.Key, cmd.N, cmd.Bounds)
+	case op_grant:
+		var l Lease
+		if l, err = m.Grant(ctx, cmd.TTL); err == nil {
+			res.Lease = l.(*lease).id
+		}
+	case op_claim, op_keepalive:
+		m.mu.Lock()
+		l, ok := m.leases[cmd.Lease]
+		m.mu.Unlock()
+		if !ok {
+			err = ErrLeaseExpired
+		} else if cmd.Op == op_claim {
+			err = l.Claim(ctx, cmd.Key, cmd.Value)
+		} else {
+			err = l.KeepAlive(ctx)
+		}
+	default:
+		err = fmt.Errorf("unknown raft command: %v", cmd.Op)
+	}
+	if err != nil {
+		res.Err = err.Error()
+	}
+	if cmd.ID != "" && cmd.Op != op_get && cmd.Op != op_list {
+		f.remember(&raft_applied{ID: cmd.ID, Time: clock, Result: res})
+	}
+	return res
+}
+
+// remember keeps the result of a command, and forgets those older than RAFT_DEDUP
+func (f *raft_fsm) remember(a *raft_applied) {
+	i := 0
+	for ; i < len(f.recent) && f.recent[i].Time < a.Time-int64(RAFT_DEDUP); i++ {
+		delete(f.applied, f.recent[i].ID)
+	}
+	f.recent = append(f.recent[i:], a)
+	f.applied[a.ID] = a
+}
+
+// raft_state is the snapshot of the fsm
+type raft_state struct {
+	Clock     int64                       `json:"clock"`
+	Revision  int64                       `json:"revision"`
+	LastLease int64                       `json:"last_lease"`
+	KVs       map[string]*raft_entry      `json:"kvs"`
+	Leases    map[int64]*raft_lease_state `json:"leases"`
+	Applied   []*raft_applied             `json:"applied,omitempty"`
+}
+
+type raft_entry struct {
+	Value    []byte `json:"value"`
+	Revision int64  `json:"revision"`
+	Lease    int64  `json:"lease,omitempty"`
+}
+
+type raft_lease_state struct {
+	TTL    time.Duration `json:"ttl"`
+	Expire int64         `json:"expire"`
+}
+
+func (f *raft_fsm) Snapshot() (raft.FSMSnapshot, error) {
+	m := f.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := &raft_state{Clock: f.clock, Revision: m.revision, LastLease: m.last_lease,
+		KVs: make(map[string]*raft_entry), Leases: make(map[int64]*raft_lease_state),
+		Applied: append([]*raft_applied(nil), f.recent...)}
+	for k, e := range m.kvs {
+		st.KVs[k] = &raft_entry{Value: e.value, Revision: e.revision, Lease: e.lease}
+	}
+	for id, l := range m.leases {
+		st.Leases[id] = &raft_lease_state{TTL: l.ttl, Expire: l.expire.UnixNano()}
+	}
+	return st, nil
+}
+
+func (f *raft_fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	st := &raft_state{}
+	if err := json.NewDecoder(rc).Decode(st); err != nil {
+		return err
+	}
+
+	m := f.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f.clock, m.revision, m.last_lease = st.Clock, st.Revision, st.LastLease
+	m.kvs = make(map[string]*entry)
+	for k, e := range st.KVs {
+		m.kvs[k] = &entry{value: e.Value, revision: e.Revision, lease: e.Lease}
+	}
+	m.leases = make(map[int64]*lease)
+	for id, l := range st.Leases {
+		m.leases[id] = &lease{m: m, id: id, ttl: l.TTL, expire: time.Unix(0, l.Expire)}
+	}
+	f.applied, f.recent = make(map[string]*raft_applied), st.Applied
+	for _, a := range st.Applied {
+		f.applied[a.ID] = a
+	}
+	return nil
+}
+
+func (st *raft_state) Persist(sink raft.SnapshotSink) error {
+	if err := json.NewEncoder(sink).Encode(st); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (st *raft_state) Release() {}
+
+// raft_mux shares one listener between raft and forwarded commands by the first byte of connections
+type raft_mux struct {
+	ln     net.Listener
+	conns  chan net.Conn // raft connections
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (mux *raft_mux) serve(forward func(net.Conn)) {
+	for {
+		conn, err := mux.ln.Accept()
+		if err != nil {
+			mux.Close()
+			return
+		}
+		go func() {
+			b := make([]byte, 1)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				conn.Close()
+				return
+			}
+			switch b[0] {
+			case RAFT_MUX_RAFT:
+				select {
+				case mux.conns <- conn:
+				case <-mux.closed:
+					conn.Close()
+				}
+			case RAFT_MUX_FWD:
+				forward(conn)
+			default:
+				conn.Close()
+			}
+		}()
+	}
+}
+
+func (mux *raft_mux) Accept() (net.Conn, error) {
+	select {
+	case conn := <-mux.conns:
+		return conn, nil
+	case <-mux.closed:
+		return nil, errors.New("raft listener closed")
+	}
+}
+
+func (mux *raft_mux) Close() error {
+	mux.once.Do(func() {
+		close(mux.closed)
+		mux.ln.Close()
+	})
+	return nil
+}
+
+func (mux *raft_mux) Addr() net.Addr {
+	return mux.ln.Addr()
+}
+
+func (mux *raft_mux) Dial(addr raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	return raft_dial(addr, timeout, RAFT_MUX_RAFT)
+}
+
+func raft_dial(addr raft.ServerAddress, timeout time.Duration, kind byte) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", string(addr), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{kind}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// raft_forwarder keeps pools of connections to leaders
+type raft_forwarder struct {
+	mu   sync.Mutex
+	pool map[raft.ServerAddress]chan *raft_conn
+}
+
+type raft_conn struct {
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+func (f *raft_forwarder) forward(ctx context.Context, leader raft.ServerAddress, cmd *raft_command) (*raft_result, error) {
+	f.mu.Lock()
+	pool, ok := f.pool[leader]
+	if !ok {
+		pool = make(chan *raft_conn, RAFT_POOL)
+		f.pool[leader] = pool
+	}
+	f.mu.Unlock()
+
+	var c *raft_conn
+	select {
+	case c = <-pool:
+	default:
+		conn, err := raft_dial(leader, RAFT_DIAL, RAFT_MUX_FWD)
+		if err != nil {
+			return nil, err
+		}
+		c = &raft_conn{conn: conn, dec: json.NewDecoder(bufio.NewReader(conn)), enc: json.NewEncoder(conn)}
+	}
+
+	// the connection is dropped if ctx is done before the reply
+	deadline := time.Now().Add(2 * RAFT_TIMEOUT)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	res := &raft_result{}
+	c.conn.SetDeadline(deadline)
+	if err := c.enc.Encode(cmd); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if err := c.dec.Decode(res); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	select {
+	case pool <- c:
+	default:
+		c.conn.Close()
+	}
+	return res, nil
+}
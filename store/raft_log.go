@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/go-msgpack/codec"
+	"github.com/hashicorp/raft"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucket_logs = []byte("logs")
+	bucket_conf = []byte("conf")
+
+	// raft recognizes a missing stable key by this message
+	errRaftKeyNotFound = errors.New("not found")
+)
+
+// raft_log keeps the raft log and stable state in a bolt database file,
+// in the layout of hashicorp/raft-boltdb, so existing raft directories still open.
+type raft_log struct {
+	db *bolt.DB
+}
+
+func new_raft_log(path string) (*raft_log, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucket_logs); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(bucket_conf)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &raft_log{db: db}, nil
+}
+
+func (l *raft_log) Close() error {
+	return l.db.Close()
+}
+
+func (l *raft_log) FirstIndex() (index uint64, err error) {
+	err = l.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(bucket_logs).Cursor().First(); k != nil {
+			index = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return
+}
+
+func (l *raft_log) LastIndex() (index uint64, err error) {
+	err = l.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(bucket_logs).Cursor().Last(); k != nil {
+			index = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return
+}
+
+func (l *raft_log) GetLog(index uint64, log *raft.Log) error {
+	return l.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucket_logs).Get(uint64_bytes(index))
+		if v == nil {
+			return raft.ErrLogNotFound
+		}
+		return codec.NewDecoder(bytes.NewReader(v), &codec.MsgpackHandle{}).Decode(log)
+	})
+}
+
+func (l *raft_log) StoreLog(log *raft.Log) error {
+	return l.StoreLogs([]*raft.Log{log})
+}
+
+func (l *raft_log) StoreLogs(logs []*raft.Log) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket_logs)
+		for _, log := range logs {
+			var buf bytes.Buffer
+			if err := codec.NewEncoder(&buf, &codec.MsgpackHandle{}).Encode(log); err != nil {
+				return err
+			}
+			if err := b.Put(uint64_bytes(log.Index), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRange deletes logs from min to max inclusively
+func (l *raft_log) DeleteRange(min, max uint64) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket_logs).Cursor()
+		for k, _ := c.Seek(uint64_bytes(min)); k != nil && binary.BigEndian.Uint64(k) <= max; k, _ = c.Next() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (l *raft_log) Set(key, value []byte) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket_conf).Put(key, value)
+	})
+}
+
+func (l *raft_log) Get(key []byte) (value []byte, err error) {
+	err = l.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bucket_conf).Get(key)
+		if v == nil {
+			return errRaftKeyNotFound
+		}
+		value = append([]byte(nil), v...)
+		return nil
+	})
+	return
+}
+
+func (l *raft_log) SetUint64(key []byte, value uint64) error {
+	return l.Set(key, uint64_bytes(value))
+}
+
+func (l *raft_log) GetUint64(key []byte) (uint64, error) {
+	v, err := l.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func uint64_bytes(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,305 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"golang.org/x/net/context"
+)
+
+var raft_bounds = Bounds{Increment: 1, MinValue: math.MinInt64, MaxValue: math.MaxInt64}
+
+// raft_cluster starts n nodes on in-process transports, forwarding by direct calls
+func raft_cluster(t *testing.T, n int) []*raft_store {
+	var peers []string
+	var transports []*raft.InmemTransport
+	for i := 0; i < n; i++ {
+		addr, trans := raft.NewInmemTransport(raft.ServerAddress(fmt.Sprintf("node%d", i)))
+		peers = append(peers, fmt.Sprintf("%d=%v", i, addr))
+		transports = append(transports, trans)
+	}
+	for _, a := range transports {
+		for _, b := range transports {
+			a.Connect(b.LocalAddr(), b)
+		}
+	}
+
+	nodes := make(map[raft.ServerAddress]*raft_store)
+	var stores []*raft_store
+	for i := 0; i < n; i++ {
+		conf := raft.DefaultConfig()
+		conf.LocalID = raft.ServerID(fmt.Sprint(i))
+		conf.HeartbeatTimeout = 50 * time.Millisecond
+		conf.ElectionTimeout = 50 * time.Millisecond
+		conf.LeaderLeaseTimeout = 50 * time.Millisecond
+		conf.CommitTimeout = 5 * time.Millisecond
+		conf.LogOutput = ioutil.Discard
+
+		logs := raft.NewInmemStore()
+		s, err := new_raft(conf, peers, logs, logs, raft.NewInmemSnapshotStore(), transports[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.forward = func(ctx context.Context, leader raft.ServerAddress, cmd *raft_command) (*raft_result, error) {
+			return nodes[leader].serve(ctx, cmd), nil
+		}
+		nodes[transports[i].LocalAddr()] = s
+		stores = append(stores, s)
+	}
+	return stores
+}
+
+func raft_leader(t *testing.T, nodes []*raft_store) (*raft_store, []*raft_store) {
+	deadline := time.Now().Add(RAFT_TIMEOUT)
+	for time.Now().Before(deadline) {
+		for i, s := range nodes {
+			if s.raft.State() == raft.Leader {
+				var followers []*raft_store
+				followers = append(followers, nodes[:i]...)
+				followers = append(followers, nodes[i+1:]...)
+				return s, followers
+			}
+		}
+		time.Sleep(RAFT_RETRY)
+	}
+	t.Fatal("no leader elected")
+	return nil, nil
+}
+
+func TestRaftReplication(t *testing.T) {
+	nodes := raft_cluster(t, 3)
+	for _, s := range nodes {
+		defer s.Close()
+	}
+	_, followers := raft_leader(t, nodes)
+
+	// writes on a follower are forwarded to the leader
+	f := followers[0]
+	if err := f.Create(ctx, "/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if first, last, err := f.Incr(ctx, "/a", 10, raft_bounds); err != nil || first != 1 || last != 10 {
+		t.Fatal(first, last, err)
+	}
+	if err := f.Create(ctx, "/a", []byte("0")); err != ErrExists {
+		t.Fatal("create existing key:", err)
+	}
+	l, err := f.Grant(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Claim(ctx, "/ids/0", []byte("me")); err != nil {
+		t.Fatal(err)
+	}
+
+	// every member applies the same state
+	for _, s := range nodes {
+		deadline := time.Now().Add(RAFT_TIMEOUT)
+		for s.raft.AppliedIndex() < followers[0].raft.LastIndex() && time.Now().Before(deadline) {
+			time.Sleep(RAFT_RETRY)
+		}
+		if kv, err := s.fsm.m.Get(ctx, "/a"); err != nil || string(kv.Value) != "10" {
+			t.Fatal(kv, err)
+		}
+		if kv, err := s.fsm.m.Get(ctx, "/ids/0"); err != nil || string(kv.Value) != "me" {
+			t.Fatal(kv, err)
+		}
+	}
+}
+
+func TestRaftFailover(t *testing.T) {
+	nodes := raft_cluster(t, 3)
+	for _, s := range nodes {
+		defer s.Close()
+	}
+	leader, followers := raft_leader(t, nodes)
+	if err := leader.Create(ctx, "/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := followers[0].Incr(ctx, "/a", 1, raft_bounds); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sequence continues on the new leader without gaps or duplicates
+	leader.Close()
+	if first, last, err := followers[1].Incr(ctx, "/a", 1, raft_bounds); err != nil || first != 2 || last != 2 {
+		t.Fatal(first, last, err)
+	}
+	if kv, err := followers[0].Get(ctx, "/a"); err != nil || string(kv.Value) != "2" {
+		t.Fatal(kv, err)
+	}
+}
+
+func TestRaftContext(t *testing.T) {
+	// a member without peers never elects a leader
+	conf := raft.DefaultConfig()
+	conf.LocalID = "0"
+	conf.LogOutput = ioutil.Discard
+	_, trans := raft.NewInmemTransport("")
+	logs := raft.NewInmemStore()
+	s, err := new_raft(conf, nil, logs, logs, raft.NewInmemSnapshotStore(), trans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.Get(timeout, "/a"); err != context.DeadlineExceeded {
+		t.Fatal("get without leader:", err)
+	}
+	if time.Since(start) > RAFT_TIMEOUT/2 {
+		t.Fatal("deadline ignored:", time.Since(start))
+	}
+}
+
+func TestRaftSnapshot(t *testing.T) {
+	f := new_raft_fsm()
+	f.clock = time.Now().UnixNano()
+	f.m.Create(ctx, "/a", []byte("1"))
+	l, _ := f.m.Grant(ctx, time.Minute)
+	l.Claim(ctx, "/ids/0", []byte("me"))
+	raft_apply(f, &raft_command{ID: "1", Op: op_incr, Key: "/a", N: 1, Bounds: raft_bounds})
+
+	snap, err := f.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := raft.NewInmemSnapshotStore()
+	w, _ := store.Create(raft.SnapshotVersionMax, 1, 1, raft.Configuration{}, 1, nil)
+	if err := snap.Persist(w); err != nil {
+		t.Fatal(err)
+	}
+	_, rc, err := store.Open(w.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := new_raft_fsm()
+	if err := g.Restore(rc); err != nil {
+		t.Fatal(err)
+	}
+	if g.clock != f.clock || g.m.revision != f.m.revision {
+		t.Fatal(g.clock, g.m.revision)
+	}
+	if kv, err := g.m.Get(ctx, "/ids/0"); err != nil || string(kv.Value) != "me" {
+		t.Fatal(kv, err)
+	}
+	if err := g.m.leases[1].KeepAlive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if res := raft_apply(g, &raft_command{ID: "1", Op: op_incr, Key: "/a", N: 1, Bounds: raft_bounds}); res.First != 2 {
+		t.Fatal("retried command applied again:", res)
+	}
+}
+
+func raft_apply(f *raft_fsm, cmd *raft_command) *raft_result {
+	data, _ := json.Marshal(cmd)
+	return f.Apply(&raft.Log{Data: data}).(*raft_result)
+}
+
+func TestRaftDedup(t *testing.T) {
+	f := new_raft_fsm()
+	f.m.Create(ctx, "/a", []byte("0"))
+	now := time.Now().UnixNano()
+
+	// retried commands return the first result, without being applied again
+	for _, cmd := range []*raft_command{
+		{ID: "1", Op: op_incr, Key: "/a", N: 10, Bounds: raft_bounds, Time: now},
+		{ID: "1", Op: op_incr, Key: "/a", N: 10, Bounds: raft_bounds, Time: now + 1},
+	} {
+		if res := raft_apply(f, cmd); res.First != 1 || res.Last != 10 {
+			t.Fatal(res)
+		}
+	}
+	if res := raft_apply(f, &raft_command{ID: "2", Op: op_create, Key: "/b", Time: now}); res.Err != "" {
+		t.Fatal(res)
+	}
+	if res := raft_apply(f, &raft_command{ID: "2", Op: op_create, Key: "/b", Time: now}); res.Err != "" {
+		t.Fatal("spurious", res.Err)
+	}
+
+	// results are forgotten by the replicated clock
+	raft_apply(f, &raft_command{ID: "3", Op: op_put, Key: "/c", Time: now + int64(RAFT_DEDUP) + 2})
+	if _, ok := f.applied["1"]; ok || len(f.recent) != 1 {
+		t.Fatal(f.recent)
+	}
+}
+
+// raft_addrs reserves n local tcp addresses
+func raft_addrs(t *testing.T, n int) []string {
+	var addrs []string
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ln.Addr().String())
+		ln.Close()
+	}
+	return addrs
+}
+
+func TestRaftTCP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	addrs := raft_addrs(t, 3)
+	var peers []string
+	for i, addr := range addrs {
+		peers = append(peers, fmt.Sprintf("%d=%v", i, addr))
+	}
+	var nodes []*raft_store
+	for i, addr := range addrs {
+		s, err := NewRaft(fmt.Sprint(i), addr, filepath.Join(dir, fmt.Sprint(i)), peers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		nodes = append(nodes, s.(*raft_store))
+	}
+	leader, followers := raft_leader(t, nodes)
+
+	// raft and forwarded commands share the listener
+	f := followers[0]
+	if err := f.Create(ctx, "/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if first, last, err := f.Incr(ctx, "/a", 10, raft_bounds); err != nil || first != 1 || last != 10 {
+		t.Fatal(first, last, err)
+	}
+
+	// a command forwarded again is applied once
+	cmd := &raft_command{ID: "retried", Op: op_incr, Key: "/a", N: 1, Bounds: raft_bounds}
+	for i := 0; i < 2; i++ {
+		res, err := f.forward(ctx, leader.raft.Leader(), cmd)
+		if err != nil || res.Err != "" || res.First != 11 {
+			t.Fatal(res, err)
+		}
+	}
+	if kv, err := f.Get(ctx, "/a"); err != nil || string(kv.Value) != "11" {
+		t.Fatal(kv, err)
+	}
+
+	// unknown connections are dropped
+	conn, err := raft_dial(raft.ServerAddress(addrs[0]), RAFT_DIAL, 'X')
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(RAFT_TIMEOUT))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatal("unknown connection served:", err)
+	}
+}
@@ -0,0 +1,505 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/net/context"
+)
+
+const (
+	REDIS_REVISION  = "snowflake:revision"  // global revision counter
+	REDIS_REVISIONS = "snowflake:revisions" // hash of the revision of each key
+	REDIS_SCAN      = 1000                  // keys per SCAN of List
+	REDIS_IDLE      = 16                    // max idle connections
+)
+
+// every script takes REDIS_REVISION and REDIS_REVISIONS as KEYS[1] and KEYS[2],
+// values are kept as plain strings, so that INCRBY works on sequences.
+const redis_lua_common = `
+local function bump(...)
+	local rev = redis.call('INCR', KEYS[1])
+	for _, k in ipairs({...}) do
+		redis.call('HSET', KEYS[2], k, rev)
+	end
+	return rev
+end
+
+-- compares two decimal integers as strings, lua numbers lose int64 precision
+local function cmp(a, b)
+	local na, nb = a:sub(1, 1) == '-', b:sub(1, 1) == '-'
+	if na ~= nb then
+		return na and -1 or 1
+	end
+	local r = 0
+	if #a ~= #b then
+		r = #a < #b and -1 or 1
+	elseif a ~= b then
+		r = a < b and -1 or 1
+	end
+	return na and -r or r
+end
+
+local function incrby(k, delta)
+	local prev = redis.call('GET', k)
+	local r = redis.pcall('INCRBY', k, delta)
+	if type(r) == 'table' then
+		return nil
+	end
+	local v = redis.call('GET', k)
+	-- overflow, for servers which wrap around
+	if (delta:sub(1, 1) == '-') ~= (cmp(v, prev) < 0) and delta ~= '0' then
+		redis.call('SET', k, prev)
+		return nil
+	end
+	return v
+end
+`
+
+var (
+	redis_get = redis.NewScript(-1, redis_lua_common+`
+local v = redis.call('GET', KEYS[3])
+if not v then
+	return false
+end
+return {v, redis.call('HGET', KEYS[2], KEYS[3]) or '0'}
+`)
+
+	redis_put = redis.NewScript(-1, redis_lua_common+`
+redis.call('SET', KEYS[3], ARGV[1])
+return bump(KEYS[3])
+`)
+
+	redis_create = redis.NewScript(-1, redis_lua_common+`
+if redis.call('EXISTS', KEYS[3]) == 1 then
+	return 0
+end
+for i = 3, #KEYS do
+	redis.call('SET', KEYS[i], ARGV[i - 2])
+end
+return bump(unpack(KEYS, 3))
+`)
+
+	redis_cas = redis.NewScript(-1, redis_lua_common+`
+if redis.call('EXISTS', KEYS[3]) == 0 then
+	return -1
+end
+if (redis.call('HGET', KEYS[2], KEYS[3]) or '0') ~= ARGV[2] then
+	return 0
+end
+redis.call('SET', KEYS[3], ARGV[1])
+return bump(KEYS[3])
+`)
+
+	redis_delete = redis.NewScript(-1, redis_lua_common+`
+local v = redis.call('GET', KEYS[3])
+if not v then
+	return false
+end
+local rev = redis.call('HGET', KEYS[2], KEYS[3]) or '0'
+for i = 3, #KEYS do
+	redis.call('DEL', KEYS[i])
+	redis.call('HDEL', KEYS[2], KEYS[i])
+end
+return {v, rev}
+`)
+
+	// plain counters without bounds, ARGV: increment * n
+	// returns {0} if not found, {1} if overflows, {2, last} on success, {3} if malformed
+	redis_incr = redis.NewScript(-1, redis_lua_common+`
+local prev = redis.call('GET', KEYS[3])
+if not prev then
+	return {0}
+end
+if not prev:match('^%-?%d+$') then
+	return {3}
+end
+local v = incrby(KEYS[3], ARGV[1])
+if not v then
+	return {1}
+end
+bump(KEYS[3])
+return {2, v}
+`)
+
+	// bounded or cycling sequences, ARGV: increment, increment * (n-1), min, max,
+	// last value after restarting from the other end, empty if not cycling.
+	redis_advance = redis.NewScript(-1, redis_lua_common+`
+local prev = redis.call('GET', KEYS[3])
+if not prev then
+	return {0}
+end
+if not prev:match('^%-?%d+$') then
+	return {3}
+end
+
+local first, last = incrby(KEYS[3], ARGV[1]), nil
+if first then
+	last = incrby(KEYS[3], ARGV[2])
+end
+local function in_range(v)
+	return cmp(v, ARGV[3]) >= 0 and cmp(v, ARGV[4]) <= 0
+end
+if not (last and in_range(first) and in_range(last)) then
+	if ARGV[5] == '' then
+		redis.call('SET', KEYS[3], prev)
+		return {1}
+	end
+	last = ARGV[5]
+	redis.call('SET', KEYS[3], last)
+end
+bump(KEYS[3])
+return {2, last}
+`)
+
+	// claims a key if it's free or still holds the same owner, ARGV: value, ttl in milliseconds
+	redis_claim = redis.NewScript(1, `
+local v = redis.call('GET', KEYS[1])
+if v == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if v == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+	// renews a key if it's still owned, ARGV: value, ttl in milliseconds
+	redis_renew = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+)
+
+// redis_store keeps sequences as plain string keys advanced by INCRBY and lua scripts,
+// revisions of keys are kept in a hash for compare-and-swap.
+type redis_store struct {
+	pool *redis.Pool
+}
+
+// redis_lease is kept alive by renewing the ttl of its keys with PEXPIRE,
+// the ttl on redis decides the expiry, keys are owned by their values.
+type redis_lease struct {
+	s       *redis_store
+	ttl     time.Duration
+	mu      sync.Mutex
+	keys    map[string][]byte // claimed keys and their values
+	expired bool              // a key has been lost
+}
+
+// NewRedis creates a Store on a redis server
+func NewRedis(addr string) Store {
+	pool := &redis.Pool{
+		MaxIdle: REDIS_IDLE,
+		Dial:    func() (redis.Conn, error) { return redis.Dial("tcp", addr) },
+	}
+	return &redis_store{pool: pool}
+}
+
+// conn takes a pooled connection, its commands are bounded by ctx
+func (s *redis_store) conn(ctx context.Context) (redis.Conn, error) {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return redis_conn{Conn: conn, ctx: ctx}, nil
+}
+
+// do runs a script on a pooled connection
+func (s *redis_store) do(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis_do(conn, script, keys, args...)
+}
+
+// redis_conn fails commands once ctx is done, and times out replies at the deadline of ctx
+type redis_conn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func (c redis_conn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return c.Conn.Do(cmd, args...)
+	}
+	reply, err := redis.DoWithTimeout(c.Conn, time.Until(deadline), cmd, args...)
+	if err != nil && c.ctx.Err() != nil {
+		return nil, c.ctx.Err()
+	}
+	return reply, err
+}
+
+// redis_do runs a script with the revision keys prepended to keys
+func redis_do(conn redis.Conn, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	keys_args := []interface{}{len(keys) + 2, REDIS_REVISION, REDIS_REVISIONS}
+	for _, k := range keys {
+		keys_args = append(keys_args, k)
+	}
+	return script.Do(conn, append(keys_args, args...)...)
+}
+
+func (s *redis_store) Get(ctx context.Context, key string) (*KV, error) {
+	values, err := redis.Values(s.do(ctx, redis_get, []string{key}))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return redis_kv(key, values)
+}
+
+func (s *redis_store) List(ctx context.Context, prefix string) ([]*KV, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// SCAN may return a key more than once
+	seen := make(map[string]bool)
+	kvs := []*KV{}
+	cursor := "0"
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", redis_escape(prefix)+"*", "COUNT", REDIS_SCAN))
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != 2 {
+			return nil, errors.New("malformed reply of SCAN")
+		}
+		if cursor, err = redis.String(reply[0], nil); err != nil {
+			return nil, err
+		}
+		keys, err := redis.Strings(reply[1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			values, err := redis.Values(redis_do(conn, redis_get, []string{key}))
+			if err == redis.ErrNil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			kv, err := redis_kv(key, values)
+			if err != nil {
+				return nil, err
+			}
+			kvs = append(kvs, kv)
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs, nil
+}
+
+func (s *redis_store) Put(ctx context.Context, key string, value []byte) error {
+	_, err := s.do(ctx, redis_put, []string{key}, value)
+	return err
+}
+
+func (s *redis_store) Create(ctx context.Context, key string, value []byte, extra ...*KV) error {
+	keys := []string{key}
+	args := []interface{}{value}
+	for _, kv := range extra {
+		keys = append(keys, kv.Key)
+		args = append(args, kv.Value)
+	}
+
+	rev, err := redis.Int64(s.do(ctx, redis_create, keys, args...))
+	if err != nil {
+		return err
+	}
+	if rev == 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+func (s *redis_store) CompareAndSwap(ctx context.Context, key string, value []byte, revision int64) error {
+	rev, err := redis.Int64(s.do(ctx, redis_cas, []string{key}, value, revision))
+	if err != nil {
+		return err
+	}
+	switch rev {
+	case -1:
+		return ErrNotFound
+	case 0:
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *redis_store) Delete(ctx context.Context, key string, extra ...string) (*KV, error) {
+	values, err := redis.Values(s.do(ctx, redis_delete, append([]string{key}, extra...)))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return redis_kv(key, values)
+}
+
+// Incr advances plain counters with INCRBY, and bounded or cycling ones with a lua script
+func (s *redis_store) Incr(ctx context.Context, key string, n int64, b Bounds) (int64, int64, error) {
+	rest, ok := mul(b.Increment, n-1)
+	if !ok {
+		return 0, 0, ErrOutOfRange
+	}
+
+	var reply []interface{}
+	var err error
+	if b.unbounded() {
+		delta, ok := mul(b.Increment, n)
+		if !ok {
+			return 0, 0, ErrOutOfRange
+		}
+		reply, err = redis.Values(s.do(ctx, redis_incr, []string{key}, delta))
+	} else {
+		// the last value after restarting from the other end
+		cycle := ""
+		if b.Cycle {
+			start := b.MinValue
+			if b.Increment < 0 {
+				start = b.MaxValue
+			}
+			if last, ok := b.last(start, n); ok {
+				cycle = strconv.FormatInt(last, 10)
+			}
+		}
+		reply, err = redis.Values(s.do(ctx, redis_advance, []string{key}, b.Increment, rest, b.MinValue, b.MaxValue, cycle))
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var status int64
+	var v string
+	if _, err := redis.Scan(reply, &status); err != nil {
+		return 0, 0, err
+	}
+	switch status {
+	case 0:
+		return 0, 0, ErrNotFound
+	case 1:
+		return 0, 0, ErrOutOfRange
+	case 3:
+		return 0, 0, ErrMalformed
+	}
+	if _, err := redis.Scan(reply[1:], &v); err != nil {
+		return 0, 0, err
+	}
+	last, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return last - rest, last, nil
+}
+
+func (s *redis_store) Grant(ctx context.Context, ttl time.Duration) (Lease, error) {
+	return &redis_lease{s: s, ttl: ttl, keys: make(map[string][]byte)}, nil
+}
+
+func (s *redis_store) Close() error {
+	return s.pool.Close()
+}
+
+// Claim sets the key with a ttl, a key still holding value is taken over,
+// so that a process takes its key back with a new lease before the ttl runs out.
+func (l *redis_lease) Claim(ctx context.Context, key string, value []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.expired {
+		return ErrLeaseExpired
+	}
+	if _, ok := l.keys[key]; ok {
+		return ErrExists
+	}
+
+	conn, err := l.s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ok, err := redis.Bool(redis_claim.Do(conn, key, value, int64(l.ttl/time.Millisecond)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrExists
+	}
+	l.keys[key] = value
+	return nil
+}
+
+// KeepAlive renews the ttl of all claimed keys, the lease expires if any of them has been lost
+func (l *redis_lease) KeepAlive(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.expired {
+		return ErrLeaseExpired
+	}
+
+	conn, err := l.s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for key, value := range l.keys {
+		ok, err := redis.Bool(redis_renew.Do(conn, key, value, int64(l.ttl/time.Millisecond)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			l.expired = true
+			return ErrLeaseExpired
+		}
+	}
+	return nil
+}
+
+// redis_kv converts a reply of {value, revision}
+func redis_kv(key string, values []interface{}) (*KV, error) {
+	kv := &KV{Key: key}
+	var rev string
+	if _, err := redis.Scan(values, &kv.Value, &rev); err != nil {
+		return nil, err
+	}
+	revision, err := strconv.ParseInt(rev, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	kv.Revision = revision
+	return kv, nil
+}
+
+// redis_escape escapes glob characters of SCAN MATCH
+func redis_escape(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return r.Replace(prefix)
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"golang.org/x/net/context"
+)
+
+func TestRedisIncr(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	s := NewRedis(m.Addr())
+	defer s.Close()
+	c := s.(Counter)
+
+	plain := Bounds{Increment: 1, MinValue: math.MinInt64, MaxValue: math.MaxInt64}
+	if _, _, err := c.Incr(ctx, "/seqs/a", 1, plain); err != ErrNotFound {
+		t.Fatal("incr missing key:", err)
+	}
+
+	// plain counters
+	if err := s.Create(ctx, "/seqs/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if first, last, err := c.Incr(ctx, "/seqs/a", 1, plain); err != nil || first != 1 || last != 1 {
+		t.Fatal(first, last, err)
+	}
+	if first, last, err := c.Incr(ctx, "/seqs/a", 10, plain); err != nil || first != 2 || last != 11 {
+		t.Fatal(first, last, err)
+	}
+	kv, err := s.Get(ctx, "/seqs/a")
+	if err != nil || string(kv.Value) != "11" {
+		t.Fatal(kv, err)
+	}
+
+	// revisions move with increments
+	if err := s.CompareAndSwap(ctx, "/seqs/a", []byte("100"), kv.Revision); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Incr(ctx, "/seqs/a", 1, plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CompareAndSwap(ctx, "/seqs/a", []byte("0"), kv.Revision); err != ErrConflict {
+		t.Fatal("swap after incr:", err)
+	}
+
+	// int64 precision and overflow
+	if err := s.Put(ctx, "/seqs/b", []byte("9223372036854775800")); err != nil {
+		t.Fatal(err)
+	}
+	if first, last, err := c.Incr(ctx, "/seqs/b", 7, plain); err != nil || first != math.MaxInt64-6 || last != math.MaxInt64 {
+		t.Fatal(first, last, err)
+	}
+	if _, _, err := c.Incr(ctx, "/seqs/b", 1, plain); err != ErrOutOfRange {
+		t.Fatal("overflow:", err)
+	}
+
+	// bounded and cycling
+	bounded := Bounds{Increment: 3, MinValue: 1, MaxValue: 10}
+	if err := s.Put(ctx, "/seqs/c", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if first, last, err := c.Incr(ctx, "/seqs/c", 2, bounded); err != nil || first != 3 || last != 6 {
+		t.Fatal(first, last, err)
+	}
+	if _, _, err := c.Incr(ctx, "/seqs/c", 2, bounded); err != ErrOutOfRange {
+		t.Fatal("exhausted:", err)
+	}
+	if kv, _ := s.Get(ctx, "/seqs/c"); string(kv.Value) != "6" {
+		t.Fatal("value changed when exhausted:", string(kv.Value))
+	}
+	bounded.Cycle = true
+	if first, last, err := c.Incr(ctx, "/seqs/c", 2, bounded); err != nil || first != 1 || last != 4 {
+		t.Fatal(first, last, err)
+	}
+
+	down := Bounds{Increment: -5, MinValue: -10, MaxValue: 10, Cycle: true}
+	if first, last, err := c.Incr(ctx, "/seqs/c", 1, down); err != nil || first != -1 || last != -1 {
+		t.Fatal(first, last, err)
+	}
+	if first, last, err := c.Incr(ctx, "/seqs/c", 2, down); err != nil || first != 10 || last != 5 {
+		t.Fatal(first, last, err)
+	}
+
+	// malformed
+	if err := s.Put(ctx, "/seqs/d", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Incr(ctx, "/seqs/d", 1, plain); err == nil {
+		t.Fatal("incr malformed value")
+	}
+}
+
+func TestRedisContext(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	s := NewRedis(m.Addr())
+	defer s.Close()
+
+	// commands are bounded by the deadline
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := s.Create(timeout, "/seqs/a", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(timeout, "/seqs/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.Get(canceled, "/seqs/a"); err != context.Canceled {
+		t.Fatal("get with canceled context:", err)
+	}
+}
+
+func TestRedisLease(t *testing.T) {
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	s := NewRedis(m.Addr())
+	defer s.Close()
+
+	l, err := s.Grant(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Claim(ctx, "/ids/0", []byte("me")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ttl on redis decides, not the local clock
+	time.Sleep(150 * time.Millisecond)
+	if err := l.KeepAlive(ctx); err != nil {
+		t.Fatal("keep alive within the ttl on redis:", err)
+	}
+
+	// the owner takes its key back with a new lease, others can't
+	other, _ := s.Grant(ctx, 100*time.Millisecond)
+	if err := other.Claim(ctx, "/ids/0", []byte("you")); err != ErrExists {
+		t.Fatal("claim a key of another owner:", err)
+	}
+	again, _ := s.Grant(ctx, 100*time.Millisecond)
+	if err := again.Claim(ctx, "/ids/0", []byte("me")); err != nil {
+		t.Fatal("claim back:", err)
+	}
+	if ttl := m.TTL("/ids/0"); ttl != 100*time.Millisecond {
+		t.Fatal("ttl not renewed:", ttl)
+	}
+
+	// a key taken by another owner expires the lease
+	m.FastForward(150 * time.Millisecond)
+	if err := other.Claim(ctx, "/ids/0", []byte("you")); err != nil {
+		t.Fatal(err)
+	}
+	if err := again.KeepAlive(ctx); err != ErrLeaseExpired {
+		t.Fatal("keep alive a lost key:", err)
+	}
+}
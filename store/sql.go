@@ -0,0 +1,373 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// all keys live in one table, sequences are decimal strings,
+// keys claimed by leases carry the lease id and an expiry in unix milliseconds.
+const sql_schema = `CREATE TABLE IF NOT EXISTS sequences (
+	name VARCHAR(255) NOT NULL PRIMARY KEY,
+	value TEXT NOT NULL,
+	revision BIGINT NOT NULL,
+	lease BIGINT NOT NULL,
+	expire BIGINT NOT NULL
+)`
+
+// sql_live matches rows which are not claimed by expired leases
+const sql_live = "(expire = 0 OR expire > ?)"
+
+// SQL_RETRY bounds the retries of Incr and Delete racing with other writers
+const SQL_RETRY = 16
+
+// sql_store keeps keys in a table of mysql, postgresql or sqlite,
+// writes are optimistic: UPDATE ... WHERE name = ? AND revision = ?,
+// sequences advance with UPDATE ... WHERE name = ? AND value = ?
+type sql_store struct {
+	db     *sql.DB
+	driver string
+}
+
+// sql_lease renews the expiry of its rows, expiry is judged by the clocks of the processes
+type sql_lease struct {
+	s       *sql_store
+	id      int64
+	ttl     time.Duration
+	mu      sync.Mutex
+	keys    int // number of claimed keys
+	renewed time.Time
+}
+
+// NewSQL creates a Store in a database, driver is one of mysql, postgres and sqlite3,
+// the driver must be registered by importing it.
+func NewSQL(driver, dsn string) (Store, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// sqlite allows one writer, serialize instead of failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+	if _, err := db.Exec(sql_schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sql_store{db: db, driver: driver}, nil
+}
+
+// bind rewrites ? placeholders for the driver
+func (s *sql_store) bind(query string) string {
+	if s.driver != "postgres" {
+		return query
+	}
+	parts := strings.Split(query, "?")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = fmt.Sprintf("$%d", i) + parts[i]
+	}
+	return strings.Join(parts, "")
+}
+
+func (s *sql_store) Get(ctx context.Context, key string) (*KV, error) {
+	kv := &KV{Key: key}
+	var value string
+	err := s.db.QueryRowContext(ctx, s.bind("SELECT value, revision FROM sequences WHERE name = ? AND "+sql_live), key, now_ms()).Scan(&value, &kv.Revision)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	kv.Value = []byte(value)
+	return kv, nil
+}
+
+func (s *sql_store) List(ctx context.Context, prefix string) ([]*KV, error) {
+	r := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	rows, err := s.db.QueryContext(ctx, s.bind("SELECT name, value, revision FROM sequences WHERE name LIKE ? ESCAPE '!' AND "+sql_live), r.Replace(prefix)+"%", now_ms())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// filtered and sorted here, collations of databases may ignore case
+	kvs := []*KV{}
+	for rows.Next() {
+		kv := &KV{}
+		var value string
+		if err := rows.Scan(&kv.Key, &value, &kv.Revision); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(kv.Key, prefix) {
+			kv.Value = []byte(value)
+			kvs = append(kvs, kv)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs, nil
+}
+
+func (s *sql_store) Put(ctx context.Context, key string, value []byte) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.put(ctx, tx, key, value); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sql_store) Create(ctx context.Context, key string, value []byte, extra ...*KV) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.insert(ctx, tx, key, value, 0, 0); err != nil {
+		tx.Rollback()
+		return s.exists_error(ctx, key, err)
+	}
+	for _, kv := range extra {
+		if err := s.put(ctx, tx, kv.Key, kv.Value); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return s.exists_error(ctx, key, err)
+	}
+	return nil
+}
+
+func (s *sql_store) CompareAndSwap(ctx context.Context, key string, value []byte, revision int64) error {
+	res, err := s.db.ExecContext(ctx, s.bind("UPDATE sequences SET value = ?, revision = ?, lease = 0, expire = 0 WHERE name = ? AND revision = ? AND "+sql_live),
+		string(value), revision+1, key, revision, now_ms())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		if _, err := s.Get(ctx, key); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+// Delete gives up with ErrConflict after SQL_RETRY concurrent writes
+func (s *sql_store) Delete(ctx context.Context, key string, extra ...string) (*KV, error) {
+	for retry := 0; retry < SQL_RETRY; retry++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		kv, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		// the revision guards against writes between the read and the delete
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := tx.ExecContext(ctx, s.bind("DELETE FROM sequences WHERE name = ? AND revision = ?"), key, kv.Revision)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			tx.Rollback()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, k := range extra {
+			if _, err := tx.ExecContext(ctx, s.bind("DELETE FROM sequences WHERE name = ?"), k); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return kv, nil
+	}
+	return nil, ErrConflict
+}
+
+// Incr advances a sequence with one conditional UPDATE on its value,
+// it gives up with ErrConflict after SQL_RETRY concurrent writes.
+func (s *sql_store) Incr(ctx context.Context, key string, n int64, b Bounds) (int64, int64, error) {
+	for retry := 0; retry < SQL_RETRY; retry++ {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		var value string
+		err := s.db.QueryRowContext(ctx, s.bind("SELECT value FROM sequences WHERE name = ? AND "+sql_live), key, now_ms()).Scan(&value)
+		if err == sql.ErrNoRows {
+			return 0, 0, ErrNotFound
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		prev, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, 0, ErrMalformed
+		}
+		first, last, ok := b.Advance(prev, n)
+		if !ok {
+			return 0, 0, ErrOutOfRange
+		}
+
+		// the value guards against writes between the read and the update
+		res, err := s.db.ExecContext(ctx, s.bind("UPDATE sequences SET value = ?, revision = revision + 1, lease = 0, expire = 0 WHERE name = ? AND value = ? AND "+sql_live),
+			strconv.FormatInt(last, 10), key, value, now_ms())
+		if err != nil {
+			return 0, 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, 0, err
+		}
+		if affected > 0 {
+			return first, last, nil
+		}
+	}
+	return 0, 0, ErrConflict
+}
+
+// Grant picks a random lease id, unique among the processes sharing the database
+func (s *sql_store) Grant(ctx context.Context, ttl time.Duration) (Lease, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	id := int64(binary.BigEndian.Uint64(b) >> 1)
+	return &sql_lease{s: s, id: id, ttl: ttl, renewed: time.Now()}, nil
+}
+
+func (s *sql_store) Close() error {
+	return s.db.Close()
+}
+
+// put updates or inserts a key in a transaction
+func (s *sql_store) put(ctx context.Context, tx *sql.Tx, key string, value []byte) error {
+	res, err := tx.ExecContext(ctx, s.bind("UPDATE sequences SET value = ?, revision = revision + 1, lease = 0, expire = 0 WHERE name = ?"), string(value), key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.bind("INSERT INTO sequences (name, value, revision, lease, expire) VALUES (?, ?, ?, 0, 0)"), key, string(value), first_revision())
+	return err
+}
+
+// insert creates a key in a transaction, replacing it if claimed by an expired lease
+func (s *sql_store) insert(ctx context.Context, tx *sql.Tx, key string, value []byte, lease, expire int64) error {
+	if _, err := tx.ExecContext(ctx, s.bind("DELETE FROM sequences WHERE name = ? AND NOT "+sql_live), key, now_ms()); err != nil {
+		return err
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, s.bind("SELECT COUNT(*) FROM sequences WHERE name = ?"), key).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrExists
+	}
+	_, err := tx.ExecContext(ctx, s.bind("INSERT INTO sequences (name, value, revision, lease, expire) VALUES (?, ?, ?, ?, ?)"),
+		key, string(value), first_revision(), lease, expire)
+	return err
+}
+
+// exists_error converts the error of a racing insert, error codes of duplicate keys differ by drivers,
+// it must be called out of transactions, sqlite has only one connection.
+func (s *sql_store) exists_error(ctx context.Context, key string, err error) error {
+	if err == ErrExists {
+		return err
+	}
+	if _, e := s.Get(ctx, key); e == nil {
+		return ErrExists
+	}
+	return err
+}
+
+// Claim inserts the key with the lease id and expiry
+func (l *sql_lease) Claim(ctx context.Context, key string, value []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.renewed) > l.ttl {
+		return ErrLeaseExpired
+	}
+
+	tx, err := l.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := l.s.insert(ctx, tx, key, value, l.id, now_ms()+int64(l.ttl/time.Millisecond)); err != nil {
+		tx.Rollback()
+		return l.s.exists_error(ctx, key, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return l.s.exists_error(ctx, key, err)
+	}
+	l.keys++
+	return nil
+}
+
+// KeepAlive extends the expiry of all claimed keys, the lease expires if any of them has been lost
+func (l *sql_lease) KeepAlive(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.renewed) > l.ttl {
+		return ErrLeaseExpired
+	}
+
+	now := time.Now()
+	res, err := l.s.db.ExecContext(ctx, l.s.bind("UPDATE sequences SET expire = ? WHERE lease = ? AND "+sql_live),
+		now.Add(l.ttl).UnixNano()/int64(time.Millisecond), l.id, now_ms())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(n) < l.keys {
+		l.renewed = time.Time{}
+		return ErrLeaseExpired
+	}
+	l.renewed = now
+	return nil
+}
+
+func now_ms() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// first_revision starts revisions of new rows at the clock,
+// so that a deleted and recreated key never reuses a revision.
+func first_revision() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLBind(t *testing.T) {
+	s := &sql_store{driver: "postgres"}
+	if q := s.bind("UPDATE t SET a = ? WHERE b = ? AND c = ?"); q != "UPDATE t SET a = $1 WHERE b = $2 AND c = $3" {
+		t.Fatal(q)
+	}
+	s.driver = "mysql"
+	if q := s.bind("SELECT ?"); q != "SELECT ?" {
+		t.Fatal(q)
+	}
+}
+
+func TestSQLList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewSQL("sqlite3", filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// LIKE wildcards in keys are matched literally
+	for _, k := range []string{"/a_b/1", "/axb/2", "/a%/3", "/A_B/4"} {
+		if err := s.Put(ctx, k, []byte("0")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kvs, err := s.List(ctx, "/a_b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 1 || kvs[0].Key != "/a_b/1" {
+		t.Fatal(kvs)
+	}
+	if kvs, err := s.List(ctx, "/a%"); err != nil || len(kvs) != 1 {
+		t.Fatal(kvs, err)
+	}
+}
+
+func TestSQLIncr(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewSQL("sqlite3", filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c := s.(Counter)
+
+	if _, _, err := c.Incr(ctx, "/a", 1, Bounds{Increment: 1, MinValue: math.MinInt64, MaxValue: math.MaxInt64}); err != ErrNotFound {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "/a", []byte("0"))
+	b := Bounds{Increment: 2, MinValue: 1, MaxValue: 10, Cycle: true}
+	for _, want := range [][2]int64{{2, 4}, {6, 8}, {1, 3}} {
+		if first, last, err := c.Incr(ctx, "/a", 2, b); err != nil || first != want[0] || last != want[1] {
+			t.Fatal(first, last, err, want)
+		}
+	}
+	if kv, err := s.Get(ctx, "/a"); err != nil || string(kv.Value) != "3" {
+		t.Fatal(kv, err)
+	}
+	b.Cycle = false
+	c.Incr(ctx, "/a", 3, b)
+	if _, _, err := c.Incr(ctx, "/a", 1, b); err != ErrOutOfRange {
+		t.Fatal(err)
+	}
+	s.Put(ctx, "/a", []byte("x"))
+	if _, _, err := c.Incr(ctx, "/a", 1, b); err != ErrMalformed {
+		t.Fatal(err)
+	}
+
+	// no value is lost between concurrent writers
+	s.Put(ctx, "/b", []byte("0"))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, _, err := c.Incr(ctx, "/b", 1, Bounds{Increment: 1, MinValue: math.MinInt64, MaxValue: math.MaxInt64}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if kv, err := s.Get(ctx, "/b"); err != nil || string(kv.Value) != "100" {
+		t.Fatal(kv, err)
+	}
+}
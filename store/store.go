@@ -0,0 +1,132 @@
+// Package store abstracts the key-value storage of sequences, options, machine ids and high-water timestamps.
+package store
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	ErrNotFound     = errors.New("key not found")
+	ErrExists       = errors.New("key already exists")
+	ErrConflict     = errors.New("key has been modified")
+	ErrLeaseExpired = errors.New("lease expired")
+	ErrOutOfRange   = errors.New("sequence reached its bound")
+	ErrMalformed    = errors.New("malformed sequence value")
+)
+
+// KV is a key with its value, Revision changes on every write of the key
+type KV struct {
+	Key      string
+	Value    []byte
+	Revision int64
+}
+
+// Store is a linearizable key-value storage,
+// calls give up when ctx is done, returning the error of ctx or of the client.
+type Store interface {
+	// Get reads a key, ErrNotFound if it doesn't exist
+	Get(ctx context.Context, key string) (*KV, error)
+	// List reads all keys with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]*KV, error)
+	// Put writes a key unconditionally
+	Put(ctx context.Context, key string, value []byte) error
+	// Create writes key and extra keys atomically if key doesn't exist, ErrExists otherwise
+	Create(ctx context.Context, key string, value []byte, extra ...*KV) error
+	// CompareAndSwap writes key if its revision is unchanged, ErrConflict or ErrNotFound otherwise
+	CompareAndSwap(ctx context.Context, key string, value []byte, revision int64) error
+	// Delete removes key and extra keys atomically if key exists, returns the removed key
+	Delete(ctx context.Context, key string, extra ...string) (*KV, error)
+	// Grant creates a lease which expires after ttl unless kept alive
+	Grant(ctx context.Context, ttl time.Duration) (Lease, error)
+	Close() error
+}
+
+// Lease binds keys to the liveness of a process
+type Lease interface {
+	// Claim creates a key attached to the lease, ErrExists if it exists,
+	// the key is removed when the lease expires.
+	Claim(ctx context.Context, key string, value []byte) error
+	// KeepAlive renews the lease, ErrLeaseExpired if it has expired
+	KeepAlive(ctx context.Context) error
+}
+
+// Bounds of a sequence, see Counter
+type Bounds struct {
+	Increment int64
+	MinValue  int64
+	MaxValue  int64
+	Cycle     bool
+}
+
+// Advance returns the first and the last of n values following prev, false if the bounds are exhausted
+func (b Bounds) Advance(prev, n int64) (int64, int64, bool) {
+	first, ok := Add(prev, b.Increment)
+	if ok && b.in_range(first) {
+		if last, ok := b.last(first, n); ok {
+			return first, last, true
+		}
+	}
+	if !b.Cycle {
+		return 0, 0, false
+	}
+
+	// restart from the other end
+	first = b.MinValue
+	if b.Increment < 0 {
+		first = b.MaxValue
+	}
+	last, ok := b.last(first, n)
+	return first, last, ok
+}
+
+// last returns the n-th value starting from first
+func (b Bounds) last(first, n int64) (int64, bool) {
+	step, ok := mul(b.Increment, n-1)
+	if !ok {
+		return 0, false
+	}
+	last, ok := Add(first, step)
+	return last, ok && b.in_range(last)
+}
+
+func (b Bounds) in_range(v int64) bool {
+	return v >= b.MinValue && v <= b.MaxValue
+}
+
+// unbounded reports whether the bounds only limit the range of int64
+func (b Bounds) unbounded() bool {
+	return b.MinValue == math.MinInt64 && b.MaxValue == math.MaxInt64 && !b.Cycle
+}
+
+// Counter is implemented by stores which advance sequences on the server side in one round trip,
+// instead of Get and CompareAndSwap retries.
+type Counter interface {
+	// Incr advances key by n increments within bounds, returns the first and the last value,
+	// ErrNotFound if key doesn't exist, ErrOutOfRange if the bounds are exhausted,
+	// ErrMalformed if the value isn't an integer, ErrConflict if concurrent writes keep winning.
+	Incr(ctx context.Context, key string, n int64, b Bounds) (int64, int64, error)
+}
+
+// Add returns a+b, and false on overflow
+func Add(a, b int64) (int64, bool) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, false
+	}
+	return a + b, true
+}
+
+// mul returns a*b, and false on overflow
+func mul(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return c, true
+}
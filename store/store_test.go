@@ -0,0 +1,247 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"golang.org/x/net/context"
+)
+
+var ctx = context.Background()
+
+// each test runs on every backend without external dependencies,
+// elapse lets the time pass for leases.
+func backends(t *testing.T, f func(t *testing.T, s Store, elapse func(time.Duration))) {
+	t.Run("memory", func(t *testing.T) {
+		f(t, NewMemory(), time.Sleep)
+	})
+	t.Run("bolt", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "store")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		s, err := NewBolt(filepath.Join(dir, "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		f(t, s, time.Sleep)
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "store")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		s, err := NewSQL("sqlite3", filepath.Join(dir, "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		f(t, s, time.Sleep)
+	})
+	t.Run("redis", func(t *testing.T) {
+		m, err := miniredis.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Close()
+		s := NewRedis(m.Addr())
+		defer s.Close()
+		f(t, s, func(d time.Duration) {
+			time.Sleep(d)
+			m.FastForward(d)
+		})
+	})
+	t.Run("raft", func(t *testing.T) {
+		s := raft_cluster(t, 1)[0]
+		defer s.Close()
+		f(t, s, time.Sleep)
+	})
+}
+
+func TestCreate(t *testing.T) {
+	backends(t, func(t *testing.T, s Store, _ func(time.Duration)) {
+		if _, err := s.Get(ctx, "/a"); err != ErrNotFound {
+			t.Fatal("get missing key:", err)
+		}
+		if err := s.Create(ctx, "/a", []byte("1"), &KV{Key: "/_options/a", Value: []byte("{}")}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Create(ctx, "/a", []byte("2")); err != ErrExists {
+			t.Fatal("create existing key:", err)
+		}
+
+		kv, err := s.Get(ctx, "/a")
+		if err != nil || string(kv.Value) != "1" {
+			t.Fatal(kv, err)
+		}
+		if kv, err := s.Get(ctx, "/_options/a"); err != nil || string(kv.Value) != "{}" {
+			t.Fatal(kv, err)
+		}
+	})
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	backends(t, func(t *testing.T, s Store, _ func(time.Duration)) {
+		if err := s.CompareAndSwap(ctx, "/a", []byte("1"), 1); err != ErrNotFound {
+			t.Fatal("swap missing key:", err)
+		}
+		if err := s.Put(ctx, "/a", []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+		kv, err := s.Get(ctx, "/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.CompareAndSwap(ctx, "/a", []byte("2"), kv.Revision); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CompareAndSwap(ctx, "/a", []byte("3"), kv.Revision); err != ErrConflict {
+			t.Fatal("swap stale revision:", err)
+		}
+		if kv, err := s.Get(ctx, "/a"); err != nil || string(kv.Value) != "2" {
+			t.Fatal(kv, err)
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	backends(t, func(t *testing.T, s Store, _ func(time.Duration)) {
+		if _, err := s.Delete(ctx, "/a"); err != ErrNotFound {
+			t.Fatal("delete missing key:", err)
+		}
+		if err := s.Create(ctx, "/a", []byte("1"), &KV{Key: "/_options/a", Value: []byte("{}")}); err != nil {
+			t.Fatal(err)
+		}
+
+		kv, err := s.Delete(ctx, "/a", "/_options/a")
+		if err != nil || string(kv.Value) != "1" {
+			t.Fatal(kv, err)
+		}
+		if _, err := s.Get(ctx, "/_options/a"); err != ErrNotFound {
+			t.Fatal("extra key not deleted:", err)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	backends(t, func(t *testing.T, s Store, _ func(time.Duration)) {
+		for _, k := range []string{"/seqs/b", "/seqs/a", "/other/c", "/seqs/x/y"} {
+			if err := s.Put(ctx, k, []byte(k)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		kvs, err := s.List(ctx, "/seqs/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := []string{}
+		for _, kv := range kvs {
+			keys = append(keys, kv.Key)
+		}
+		if len(keys) != 3 || keys[0] != "/seqs/a" || keys[1] != "/seqs/b" || keys[2] != "/seqs/x/y" {
+			t.Fatal(keys)
+		}
+	})
+}
+
+func TestLease(t *testing.T) {
+	backends(t, func(t *testing.T, s Store, elapse func(time.Duration)) {
+		l, err := s.Grant(ctx, 100*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Claim(ctx, "/ids/0", []byte("me")); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Claim(ctx, "/ids/0", []byte("me")); err != ErrExists {
+			t.Fatal("claim twice:", err)
+		}
+
+		// kept alive beyond ttl
+		for i := 0; i < 3; i++ {
+			elapse(50 * time.Millisecond)
+			if err := l.KeepAlive(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.Get(ctx, "/ids/0"); err != nil {
+			t.Fatal(err)
+		}
+
+		// expired
+		elapse(150 * time.Millisecond)
+		if _, err := s.Get(ctx, "/ids/0"); err != ErrNotFound {
+			t.Fatal("key of expired lease:", err)
+		}
+		if err := l.KeepAlive(ctx); err != ErrLeaseExpired {
+			t.Fatal("keep alive expired lease:", err)
+		}
+		if err := l.Claim(ctx, "/ids/1", nil); err != ErrLeaseExpired {
+			t.Fatal("claim with expired lease:", err)
+		}
+	})
+}
+
+func TestBoltReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.db")
+
+	s, err := NewBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "/a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, "/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "/b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	l, err := s.Grant(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Claim(ctx, "/ids/0", nil); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := s.Get(ctx, "/b")
+	s.Close()
+
+	// keys and revisions survive, leased keys don't
+	s, err = NewBolt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get(ctx, "/a"); err != ErrNotFound {
+		t.Fatal("deleted key:", err)
+	}
+	after, err := s.Get(ctx, "/b")
+	if err != nil || string(after.Value) != "2" || after.Revision != before.Revision {
+		t.Fatal(after, err)
+	}
+	if _, err := s.Get(ctx, "/ids/0"); err != ErrNotFound {
+		t.Fatal("leased key:", err)
+	}
+	if err := s.Put(ctx, "/c", nil); err != nil {
+		t.Fatal(err)
+	}
+	if kv, _ := s.Get(ctx, "/c"); kv.Revision <= before.Revision {
+		t.Fatal("revision went backward:", kv.Revision)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"snowflake/errdetail"
+	pb "snowflake/proto"
+	"snowflake/store"
+
+	cli "gopkg.in/urfave/cli.v2"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	TEMPLATES_DIR = "_templates" // hidden directory of auto-create templates under pk-root
+)
+
+// seq_template describes sequences created on first use, stored as json in <pk-root>/_templates/<glob>,
+// eg: <pk-root>/_templates/orders/* -> {"start":100000,"increment":1}
+// the glob follows path.Match, so * never crosses a /.
+type seq_template struct {
+	Start *int64 `json:"start,omitempty"` // first value, --auto-create-start if omitted
+	seq_options
+	MinValue *int64      `json:"min_value,omitempty"` // unbounded if omitted
+	MaxValue *int64      `json:"max_value,omitempty"`
+	Format   *seq_format `json:"format,omitempty"`
+}
+
+// init_templates parses auto-create flags, and writes the templates of --templates into the store
+func (s *server) init_templates(c *cli.Context) error {
+	s.auto_create = c.Bool("auto-create")
+	s.auto_start = c.Int64("auto-create-start")
+
+	// format: glob=json
+	for _, v := range c.StringSlice("templates") {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return fmt.Errorf("malformed template: %v", v)
+		}
+		if _, err := path.Match(v[:i], ""); err != nil {
+			return fmt.Errorf("malformed template %v: %v", v, err)
+		}
+		if _, _, _, err := s.parse_template([]byte(v[i+1:])); err != nil {
+			return fmt.Errorf("malformed template %v: %v", v, err)
+		}
+		if err := s.store.Put(context.Background(), s.template_key(v[:i]), []byte(v[i+1:])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create_missing creates a missing sequence from the most specific matching template,
+// a sequence created concurrently by others is fine.
+func (s *server) create_missing(ctx context.Context, name string) error {
+	key, err := s.seq_key(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	glob, value, opts, f, err := s.match_template(ctx, name)
+	if err != nil {
+		return err
+	}
+	okv, err := s.options_kv(name, opts)
+	if err != nil {
+		return status_error(ctx, codes.Internal, errdetail.INTERNAL, "%v", err)
+	}
+	extra := []*store.KV{okv}
+	if f != nil {
+		fkv, err := s.format_kv(name, f)
+		if err != nil {
+			return status_error(ctx, codes.Internal, errdetail.INTERNAL, "%v", err)
+		}
+		extra = append(extra, fkv)
+	}
+
+	err = s.store.Create(ctx, key, []byte(fmt.Sprint(value)), extra...)
+	if err == store.ErrExists {
+		return nil
+	}
+	if err != nil {
+		log.Error(err)
+		return store_error(ctx, err)
+	}
+	s.drop_segment(name)
+	if glob == "" {
+		log.Infof("sequence %v auto-created", name)
+	} else {
+		log.Infof("sequence %v auto-created from template %q", name, glob)
+	}
+	return nil
+}
+
+// match_template returns the initial value, options and format of a new sequence,
+// the template with the longest literal prefix wins, defaults if none matches.
+func (s *server) match_template(ctx context.Context, name string) (string, int64, *seq_options, *seq_format, error) {
+	kvs, err := s.store.List(ctx, s.template_key(""))
+	if err != nil {
+		log.Error(err)
+		return "", 0, nil, nil, store_error(ctx, err)
+	}
+
+	var best *store.KV
+	var glob string
+	for _, kv := range kvs {
+		g := strings.TrimPrefix(kv.Key, s.template_key(""))
+		if ok, _ := path.Match(g, name); !ok {
+			continue
+		}
+		if best == nil || literal_prefix(g) > literal_prefix(glob) {
+			best, glob = kv, g
+		}
+	}
+	if best == nil {
+		value, opts, f, err := s.parse_template([]byte("{}"))
+		return "", value, opts, f, err
+	}
+
+	value, opts, f, err := s.parse_template(best.Value)
+	if err != nil {
+		return "", 0, nil, nil, status_error(ctx, codes.DataLoss, errdetail.MALFORMED_VALUE, "malformed template %v: %v", glob, err)
+	}
+	return glob, value, opts, f, nil
+}
+
+// parse_template validates a template, returns the initial value, which precedes start,
+// the options and the format, nil for the default.
+func (s *server) parse_template(b []byte) (int64, *seq_options, *seq_format, error) {
+	t := &seq_template{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return 0, nil, nil, err
+	}
+	in := &pb.Snowflake_Options{
+		Increment:   t.Increment,
+		Cycle:       t.Cycle,
+		ResetPeriod: t.ResetPeriod,
+		Timezone:    t.Timezone,
+	}
+	if t.MinValue != nil {
+		in.MinValue, in.HasMinValue = *t.MinValue, true
+	}
+	if t.MaxValue != nil {
+		in.MaxValue, in.HasMaxValue = *t.MaxValue, true
+	}
+	opts, err := new_options(in)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var f *seq_format
+	if t.Format != nil {
+		in := &pb.Snowflake_Format{
+			Template:   t.Format.Template,
+			Prefix:     t.Format.Prefix,
+			Padding:    int32(t.Format.Padding),
+			CheckDigit: t.Format.CheckDigit,
+		}
+		if f, err = new_format(in); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	start := s.auto_start
+	if t.Start != nil {
+		start = *t.Start
+	}
+	if start < opts.MinValue || start > opts.MaxValue {
+		return 0, nil, nil, errors.New("start out of the range of min_value and max_value")
+	}
+	value, ok := store.Add(start, -opts.Increment)
+	if !ok {
+		return 0, nil, nil, errors.New("start out of range")
+	}
+	return value, opts, f, nil
+}
+
+// template_key returns the key of a template
+func (s *server) template_key(glob string) string {
+	return s.pkroot + "/" + TEMPLATES_DIR + "/" + glob
+}
+
+// literal_prefix returns the length of a glob before its first meta character
+func literal_prefix(glob string) int {
+	if i := strings.IndexAny(glob, `*?[\`); i >= 0 {
+		return i
+	}
+	return len(glob)
+}
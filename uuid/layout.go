@@ -0,0 +1,109 @@
+// Package uuid describes the bit layout of snowflake uuids, and composes or decodes them.
+package uuid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MAX_BITS = 63 // the sign bit is never used
+
+// Layout describes how a uuid is split into timestamp, machine id and serial number,
+// from the most significant bit to the least:
+//
+// 0		0.................0		0..............0	0........0
+// 1-bit	TimestampBits			MachineIDBits		SequenceBits
+type Layout struct {
+	TimestampBits uint          // width of timestamp
+	MachineIDBits uint          // width of machine id
+	SequenceBits  uint          // width of serial number
+	Unit          time.Duration // time unit of timestamp
+}
+
+var (
+	// Snowflake is the twitter snowflake layout, 41bit milliseconds, 10bit machine-id, 12bit sn
+	Snowflake = Layout{41, 10, 12, time.Millisecond}
+	// Sonyflake is the sony sonyflake layout, 39bit 10-milliseconds, 16bit machine-id, 8bit sn
+	Sonyflake = Layout{39, 16, 8, 10 * time.Millisecond}
+	// JS53 fits into 53 bits, safe for javascript numbers, 41bit milliseconds, 4bit machine-id, 8bit sn
+	JS53 = Layout{41, 4, 8, time.Millisecond}
+)
+
+var presets = map[string]Layout{
+	"snowflake": Snowflake,
+	"sonyflake": Sonyflake,
+	"js53":      JS53,
+}
+
+// ParseLayout accepts a preset name(snowflake, sonyflake, js53) or a custom layout
+// in the form of "timestamp/machine-id/sequence/unit", eg: "39/16/8/10ms"
+func ParseLayout(v string) (Layout, error) {
+	if l, ok := presets[v]; ok {
+		return l, nil
+	}
+
+	parts := strings.Split(v, "/")
+	if len(parts) != 4 {
+		return Layout{}, fmt.Errorf("malformed layout: %v", v)
+	}
+
+	var bits [3]uint
+	for i := range bits {
+		n, err := strconv.ParseUint(parts[i], 10, 8)
+		if err != nil {
+			return Layout{}, fmt.Errorf("malformed layout: %v", v)
+		}
+		bits[i] = uint(n)
+	}
+
+	unit, err := time.ParseDuration(parts[3])
+	if err != nil {
+		return Layout{}, fmt.Errorf("malformed layout: %v", v)
+	}
+
+	l := Layout{bits[0], bits[1], bits[2], unit}
+	if err := l.Validate(); err != nil {
+		return Layout{}, err
+	}
+	return l, nil
+}
+
+// Validate checks the layout fits into 63 bits, and its time range into time.Duration
+func (l Layout) Validate() error {
+	if l.TimestampBits == 0 || l.MachineIDBits == 0 || l.SequenceBits == 0 {
+		return errors.New("layout widths must be positive")
+	}
+	if l.TimestampBits+l.MachineIDBits+l.SequenceBits > MAX_BITS {
+		return fmt.Errorf("layout %v exceeds %v bits", l, MAX_BITS)
+	}
+	if l.Unit <= 0 {
+		return errors.New("layout time unit must be positive")
+	}
+	if l.TimestampMask() > uint64(math.MaxInt64/int64(l.Unit)) {
+		return fmt.Errorf("layout %v: timestamps overflow time.Duration", l)
+	}
+	return nil
+}
+
+// TimestampMask returns the mask of an unshifted timestamp
+func (l Layout) TimestampMask() uint64 { return 1<<l.TimestampBits - 1 }
+
+// MachineIDMask returns the mask of an unshifted machine id
+func (l Layout) MachineIDMask() uint64 { return 1<<l.MachineIDBits - 1 }
+
+// SequenceMask returns the mask of a serial number
+func (l Layout) SequenceMask() uint64 { return 1<<l.SequenceBits - 1 }
+
+// TimestampShift returns the offset of timestamp
+func (l Layout) TimestampShift() uint { return l.MachineIDBits + l.SequenceBits }
+
+// MachineIDShift returns the offset of machine id
+func (l Layout) MachineIDShift() uint { return l.SequenceBits }
+
+func (l Layout) String() string {
+	return fmt.Sprintf("%v/%v/%v/%v", l.TimestampBits, l.MachineIDBits, l.SequenceBits, l.Unit)
+}
@@ -0,0 +1,43 @@
+package uuid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLayout(t *testing.T) {
+	l, err := ParseLayout("snowflake")
+	if err != nil || l != Snowflake {
+		t.Fatalf("snowflake preset: %v %v", l, err)
+	}
+
+	l, err = ParseLayout("39/16/8/10ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != Sonyflake {
+		t.Fatalf("expect %v, got %v", Sonyflake, l)
+	}
+
+	for _, v := range []string{"", "41/10/12", "41/10/x/1ms", "41/10/12/x", "41/10/13/1ms", "0/10/12/1ms", "41/10/12/0s", "41/10/12/10ms"} {
+		if _, err := ParseLayout(v); err == nil {
+			t.Fatalf("layout %q should be rejected", v)
+		}
+	}
+}
+
+func TestLayoutMasks(t *testing.T) {
+	l := Snowflake
+	if l.TimestampMask() != 0x1FFFFFFFFFF || l.MachineIDMask() != 0x3FF || l.SequenceMask() != 0xFFF {
+		t.Fatal("snowflake masks mismatch")
+	}
+	if l.TimestampShift() != 22 || l.MachineIDShift() != 12 {
+		t.Fatal("snowflake shifts mismatch")
+	}
+	if JS53.TimestampBits+JS53.MachineIDBits+JS53.SequenceBits != 53 {
+		t.Fatal("js53 is not 53 bits")
+	}
+	if JS53.Unit != time.Millisecond {
+		t.Fatal("js53 unit mismatch")
+	}
+}
@@ -0,0 +1,42 @@
+package uuid
+
+import (
+	"fmt"
+	"time"
+)
+
+// ID is a decoded uuid
+type ID struct {
+	Time      time.Time // generation time, truncated to layout time unit
+	MachineID uint64    // machine id of the generator
+	Sequence  uint64    // serial number within the time unit
+}
+
+// Decode splits a uuid into timestamp, machine id and serial number,
+// epoch is the time which timestamps count from.
+func (l Layout) Decode(uuid uint64, epoch time.Time) ID {
+	ts := (uuid >> l.TimestampShift()) & l.TimestampMask()
+	return ID{
+		Time:      epoch.Add(time.Duration(ts) * l.Unit),
+		MachineID: (uuid >> l.MachineIDShift()) & l.MachineIDMask(),
+		Sequence:  uuid & l.SequenceMask(),
+	}
+}
+
+// Compose is the inverse of Decode
+func (l Layout) Compose(id ID, epoch time.Time) (uint64, error) {
+	if id.Time.Before(epoch) {
+		return 0, fmt.Errorf("time %v is earlier than epoch %v", id.Time, epoch)
+	}
+	ts := uint64(id.Time.Sub(epoch) / l.Unit)
+	if ts > l.TimestampMask() {
+		return 0, fmt.Errorf("time %v overflows layout %v", id.Time, l)
+	}
+	if id.MachineID > l.MachineIDMask() {
+		return 0, fmt.Errorf("machine id %v overflows layout %v", id.MachineID, l)
+	}
+	if id.Sequence > l.SequenceMask() {
+		return 0, fmt.Errorf("serial number %v overflows layout %v", id.Sequence, l)
+	}
+	return ts<<l.TimestampShift() | id.MachineID<<l.MachineIDShift() | id.Sequence, nil
+}
@@ -0,0 +1,46 @@
+package uuid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeCompose(t *testing.T) {
+	epoch := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	id := ID{Time: epoch.Add(123456789 * time.Millisecond), MachineID: 1023, Sequence: 4095}
+
+	uuid, err := Snowflake.Compose(id, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuid != 123456789<<22|1023<<12|4095 {
+		t.Fatalf("unexpected uuid %b", uuid)
+	}
+	if d := Snowflake.Decode(uuid, epoch); d != id {
+		t.Fatalf("expect %v, got %v", id, d)
+	}
+
+	// sonyflake truncates time to 10ms
+	uuid, err = Sonyflake.Compose(ID{Time: epoch.Add(15 * time.Millisecond), MachineID: 1, Sequence: 2}, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := Sonyflake.Decode(uuid, epoch); !d.Time.Equal(epoch.Add(10*time.Millisecond)) || d.MachineID != 1 || d.Sequence != 2 {
+		t.Fatalf("unexpected decoded id %v", d)
+	}
+}
+
+func TestComposeOverflow(t *testing.T) {
+	epoch := time.Unix(0, 0)
+	now := time.Now()
+	for _, id := range []ID{
+		{Time: epoch.Add(-time.Millisecond)},
+		{Time: now, MachineID: 1024},
+		{Time: now, Sequence: 4096},
+		{Time: epoch.Add(1 << 41 * time.Millisecond)},
+	} {
+		if _, err := Snowflake.Compose(id, epoch); err == nil {
+			t.Fatalf("id %v should be rejected", id)
+		}
+	}
+}